@@ -0,0 +1,138 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WindowKeyPrefix es el prefijo de los hashes de ventana. Cada ventana fija
+// (tumbling) de una granularidad es un hash
+// "country_counts:<granularidad>:<inicio UTC>" país -> reportes, por ejemplo
+// "country_counts:minute:2025-05-01T14:03".
+const WindowKeyPrefix = "country_counts"
+
+// maxClockSkew es cuánto puede adelantarse un event_time al reloj del
+// consumidor; más allá se asume un reloj mal configurado en el cliente.
+const maxClockSkew = time.Minute
+
+// UnknownCountry es el país con el que se cuentan los reportes sin país.
+const UnknownCountry = "UNKNOWN"
+
+// Window es una granularidad de ventana. Una ventana se conserva Retention
+// después de cerrarse; mientras tanto acepta reportes atrasados.
+type Window struct {
+	Name      string
+	Size      time.Duration
+	Layout    string // formato del inicio de la ventana en la clave
+	Retention time.Duration
+}
+
+// Aggregator decide en qué contadores cuenta cada reporte. Todos los sinks
+// comparten el mismo, así cuentan los reportes de la misma forma.
+type Aggregator struct {
+	Windows []Window
+}
+
+// NewAggregatorFromEnv lee la retención de cada granularidad de
+// WINDOW_RETENTION_MINUTE, WINDOW_RETENTION_HOUR y WINDOW_RETENTION_DAY.
+func NewAggregatorFromEnv() *Aggregator {
+	return &Aggregator{Windows: []Window{
+		{Name: "minute", Size: time.Minute, Layout: "2006-01-02T15:04", Retention: EnvDuration("WINDOW_RETENTION_MINUTE", 2*time.Hour)},
+		{Name: "hour", Size: time.Hour, Layout: "2006-01-02T15", Retention: EnvDuration("WINDOW_RETENTION_HOUR", 7*24*time.Hour)},
+		{Name: "day", Size: 24 * time.Hour, Layout: "2006-01-02", Retention: EnvDuration("WINDOW_RETENTION_DAY", 90*24*time.Hour)},
+	}}
+}
+
+// Tally es dónde cuenta un reporte.
+type Tally struct {
+	Country string
+	Weather Weather
+	Windows []WindowBucket
+	Late    []string // granularidades en las que llegó cuando su ventana ya había expirado
+}
+
+// WindowBucket es una ventana concreta: su clave y cuándo expira.
+type WindowBucket struct {
+	Key      string
+	ExpireAt int64 // segundos Unix
+}
+
+// Tally ubica un reporte procesado en now.
+func (a *Aggregator) Tally(msg WeatherMessage, now time.Time) Tally {
+	tally := Tally{Country: msg.Country, Weather: msg.Weather}
+	if tally.Country == "" {
+		tally.Country = UnknownCountry
+	}
+	tally.Windows, tally.Late = a.windowBuckets(EventTime(msg, now), now)
+	return tally
+}
+
+// EventTime ubica el reporte en el tiempo: su event_time o, si falta o está
+// en el futuro, ingested_at; si tampoco sirve, now.
+func EventTime(msg WeatherMessage, now time.Time) time.Time {
+	for _, at := range []time.Time{msg.EventTime, msg.IngestedAt} {
+		if !at.IsZero() && at.Before(now.Add(maxClockSkew)) {
+			return at
+		}
+	}
+	return now
+}
+
+// windowBuckets devuelve las ventanas en las que cuenta un reporte ocurrido en
+// at y las granularidades en las que llegó tarde: su ventana ya expiró y
+// volver a crearla mostraría un conteo parcial.
+func (a *Aggregator) windowBuckets(at, now time.Time) (buckets []WindowBucket, late []string) {
+	for _, w := range a.Windows {
+		start := at.UTC().Truncate(w.Size)
+		expireAt := start.Add(w.Size + w.Retention)
+		if !expireAt.After(now) {
+			late = append(late, w.Name)
+			continue
+		}
+		buckets = append(buckets, WindowBucket{
+			Key:      fmt.Sprintf("%s:%s:%s", WindowKeyPrefix, w.Name, start.Format(w.Layout)),
+			ExpireAt: expireAt.Unix(),
+		})
+	}
+	return buckets, late
+}
+
+// windowBatch reúne las ventanas de un lote para un script de Lua: cada clave
+// distinta va una sola vez en KEYS y los mensajes la referencian por posición
+// (desde 1).
+type windowBatch struct {
+	keys     []string
+	expireAt []interface{}
+	slots    map[string]int
+}
+
+func newWindowBatch() *windowBatch {
+	return &windowBatch{slots: make(map[string]int)}
+}
+
+// add registra las ventanas de un reporte y devuelve sus posiciones.
+func (b *windowBatch) add(buckets []WindowBucket) []int {
+	slots := make([]int, len(buckets))
+	for i, bucket := range buckets {
+		slot, ok := b.slots[bucket.Key]
+		if !ok {
+			b.keys = append(b.keys, bucket.Key)
+			b.expireAt = append(b.expireAt, bucket.ExpireAt)
+			slot = len(b.keys)
+			b.slots[bucket.Key] = slot
+		}
+		slots[i] = slot
+	}
+	return slots
+}
+
+// joinSlots codifica posiciones como "1,2,3" para pasarlas en ARGV.
+func joinSlots(slots []int) string {
+	parts := make([]string, len(slots))
+	for i, slot := range slots {
+		parts[i] = strconv.Itoa(slot)
+	}
+	return strings.Join(parts, ",")
+}
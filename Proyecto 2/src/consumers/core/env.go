@@ -0,0 +1,46 @@
+// Package core reúne lo que comparten los consumidores de Kafka y RabbitMQ:
+// el formato de los mensajes, la abstracción de la fuente (Source), el
+// batcher que agrupa los mensajes en lotes (Pipeline), el agregador de
+// contadores, los sinks y el servidor de health y métricas.
+package core
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// GetEnv devuelve la variable de entorno key o defaultValue si no está definida.
+func GetEnv(key, defaultValue string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return defaultValue
+}
+
+// EnvInt lee un entero positivo de key; termina el proceso si no es válido.
+func EnvInt(key string, def int) int {
+	value := GetEnv(key, "")
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		log.Fatalf("Invalid %s: %q", key, value)
+	}
+	return n
+}
+
+// EnvDuration lee una duración positiva de key; termina el proceso si no es válida.
+func EnvDuration(key string, def time.Duration) time.Duration {
+	value := GetEnv(key, "")
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Fatalf("Invalid %s: %q", key, value)
+	}
+	return d
+}
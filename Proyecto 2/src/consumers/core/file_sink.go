@@ -0,0 +1,82 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// fileSink archiva los reportes válidos en un archivo NDJSON, un
+// WeatherMessage por línea, siempre al final del archivo. Sirve de respaldo
+// para reconstruir los contadores o cargar los reportes en otra herramienta.
+type fileSink struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+}
+
+func newFileSink(path string) (*fileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &fileSink{path: path, file: file, w: bufio.NewWriter(file)}, nil
+}
+
+func (s *fileSink) Name() string { return "file" }
+
+// Apply codifica el lote completo antes de escribirlo, así un error de
+// codificación no deja medio lote en el archivo.
+func (s *fileSink) Apply(_ context.Context, batch Batch) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, record := range batch.Records {
+		if !record.Valid {
+			continue
+		}
+		if err := enc.Encode(record.Message); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.w.Write(buf.Bytes())
+	return err
+}
+
+// Flush escribe lo pendiente y espera a que llegue al disco.
+func (s *fileSink) Flush(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+func (s *fileSink) Health(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.file.Stat()
+	return err
+}
+
+func (s *fileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.w.Flush(); err != nil {
+		s.file.Close()
+		return err
+	}
+	return s.file.Close()
+}
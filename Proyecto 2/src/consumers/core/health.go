@@ -0,0 +1,71 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync/atomic"
+)
+
+// Contadores del proceso que muestran /health y /metrics.
+var (
+	processedCount atomic.Int64
+	errorCount     atomic.Int64
+	batchCount     atomic.Int64
+)
+
+// CountError suma un error al contador del proceso.
+func CountError() {
+	errorCount.Add(1)
+}
+
+// Processed y Errors devuelven los contadores del proceso.
+func Processed() int64 { return processedCount.Load() }
+func Errors() int64    { return errorCount.Load() }
+
+// ServeHealth atiende /health y /metrics en addr hasta que el servidor falla.
+//
+// /health responde 200 con "OK" y los contadores si la fuente y todos los
+// sinks están disponibles, o 503 con el primero que falla. /metrics expone
+// los contadores en el formato de texto de Prometheus.
+func ServeHealth(addr string, source Source, sinks []Sink) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		for _, sink := range sinks {
+			if err := sink.Health(r.Context()); err != nil {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				fmt.Fprintf(w, "Sink %s error: %v\n", sink.Name(), err)
+				return
+			}
+		}
+		if err := source.Health(r.Context()); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(w, "%s connection error: %v\n", source.Name(), err)
+			return
+		}
+		fmt.Fprintf(w, "OK\nSource: %s\nSinks: %d\nProcessed: %d\nErrors: %d\n", source.Name(), len(sinks), Processed(), Errors())
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		counter := func(name, help string, value int64) {
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s{source=%q} %d\n", name, help, name, name, source.Name(), value)
+		}
+		counter("consumer_messages_processed_total", "Messages read from the broker.", Processed())
+		counter("consumer_errors_total", "Errors while decoding, writing or acknowledging messages.", Errors())
+		counter("consumer_batches_total", "Batches written to every sink and acknowledged.", batchCount.Load())
+
+		fmt.Fprintf(w, "# HELP consumer_sink_up Whether the sink answered its health check.\n# TYPE consumer_sink_up gauge\n")
+		for _, sink := range sinks {
+			up := 1
+			if err := sink.Health(r.Context()); err != nil {
+				up = 0
+			}
+			fmt.Fprintf(w, "consumer_sink_up{source=%q,sink=%q} %d\n", source.Name(), sink.Name(), up)
+		}
+	})
+
+	log.Printf("Health check server running on %s", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf("Health check server error: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"context"
+	"sync"
+)
+
+// memorySinkMaxReports es cuántos reportes recientes guarda MemorySink.
+const memorySinkMaxReports = 1000
+
+// MemorySink lleva los contadores y los últimos reportes en memoria. No
+// persiste nada: sirve para pruebas y para correr el consumidor sin Redis.
+type MemorySink struct {
+	agg *Aggregator
+
+	mu        sync.Mutex
+	total     int64
+	countries map[string]int64
+	weathers  map[string]map[Weather]int64 // país -> clima -> reportes
+	windows   map[string]map[string]int64  // ventana -> país -> reportes
+	reports   []WeatherMessage
+}
+
+// MemorySnapshot es una copia del estado de un MemorySink.
+type MemorySnapshot struct {
+	Total     int64
+	Countries map[string]int64
+	Weathers  map[string]map[Weather]int64
+	Windows   map[string]map[string]int64
+	Reports   []WeatherMessage
+}
+
+func NewMemorySink(agg *Aggregator) *MemorySink {
+	return &MemorySink{
+		agg:       agg,
+		countries: make(map[string]int64),
+		weathers:  make(map[string]map[Weather]int64),
+		windows:   make(map[string]map[string]int64),
+	}
+}
+
+func (s *MemorySink) Name() string { return "memory" }
+
+func (s *MemorySink) Apply(_ context.Context, batch Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, record := range batch.Records {
+		s.total++
+		if !record.Valid {
+			continue
+		}
+		tally := s.agg.Tally(record.Message, batch.Now)
+		s.countries[tally.Country]++
+		if s.weathers[tally.Country] == nil {
+			s.weathers[tally.Country] = make(map[Weather]int64)
+		}
+		s.weathers[tally.Country][tally.Weather]++
+		for _, bucket := range tally.Windows {
+			if s.windows[bucket.Key] == nil {
+				s.windows[bucket.Key] = make(map[string]int64)
+			}
+			s.windows[bucket.Key][tally.Country]++
+		}
+		s.reports = append(s.reports, record.Message)
+	}
+	if extra := len(s.reports) - memorySinkMaxReports; extra > 0 {
+		s.reports = append(s.reports[:0:0], s.reports[extra:]...)
+	}
+	return nil
+}
+
+func (s *MemorySink) Flush(context.Context) error  { return nil }
+func (s *MemorySink) Health(context.Context) error { return nil }
+func (s *MemorySink) Close() error                 { return nil }
+
+// Snapshot devuelve una copia del estado actual.
+func (s *MemorySink) Snapshot() MemorySnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap := MemorySnapshot{
+		Total:     s.total,
+		Countries: make(map[string]int64, len(s.countries)),
+		Weathers:  make(map[string]map[Weather]int64, len(s.weathers)),
+		Windows:   make(map[string]map[string]int64, len(s.windows)),
+		Reports:   append([]WeatherMessage(nil), s.reports...),
+	}
+	for country, n := range s.countries {
+		snap.Countries[country] = n
+	}
+	for country, weathers := range s.weathers {
+		snap.Weathers[country] = make(map[Weather]int64, len(weathers))
+		for weather, n := range weathers {
+			snap.Weathers[country][weather] = n
+		}
+	}
+	for key, countries := range s.windows {
+		snap.Windows[key] = make(map[string]int64, len(countries))
+		for country, n := range countries {
+			snap.Windows[key][country] = n
+		}
+	}
+	return snap
+}
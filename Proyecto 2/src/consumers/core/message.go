@@ -0,0 +1,70 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// WeatherMessage es el mensaje JSON que publican los writers en los brokers.
+type WeatherMessage struct {
+	ID          string    `json:"id"`
+	Description string    `json:"description"`
+	Country     string    `json:"country"`
+	Weather     Weather   `json:"weather"`
+	EventTime   time.Time `json:"event_time"`  // Momento del reporte (lo fija el cliente o el entrypoint)
+	IngestedAt  time.Time `json:"ingested_at"` // Momento en que el entrypoint aceptó el reporte
+	Source      string    `json:"source"`
+}
+
+// Weather es el clima de un reporte. Refleja el enum Weather del contrato
+// proto (go-grpc/internal/proto/weather.proto), que los writers publican con
+// el nombre en minúsculas de go-grpc/internal/message.
+type Weather string
+
+const (
+	WeatherLluvioso Weather = "lluvioso"
+	WeatherNubloso  Weather = "nubloso"
+	WeatherSoleado  Weather = "soleado"
+)
+
+// WeatherValues son los valores válidos, en el orden del enum.
+var WeatherValues = []Weather{WeatherLluvioso, WeatherNubloso, WeatherSoleado}
+
+// Valid indica si w pertenece al enum.
+func (w Weather) Valid() bool {
+	for _, value := range WeatherValues {
+		if w == value {
+			return true
+		}
+	}
+	return false
+}
+
+// UnmarshalJSON rechaza los valores que no pertenecen al enum, así un mensaje
+// con un clima inválido falla al decodificarse como cualquier otro mensaje mal
+// formado.
+func (w *Weather) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+	if !Weather(name).Valid() {
+		return fmt.Errorf("unknown weather %q", name)
+	}
+	*w = Weather(name)
+	return nil
+}
+
+// DecodeWeatherMessage decodifica un mensaje del broker y exige que traiga un
+// clima válido.
+func DecodeWeatherMessage(data []byte) (WeatherMessage, error) {
+	var msg WeatherMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return msg, err
+	}
+	if msg.Weather == "" {
+		return msg, fmt.Errorf("missing weather")
+	}
+	return msg, nil
+}
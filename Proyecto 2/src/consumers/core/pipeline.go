@@ -0,0 +1,185 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// errLaneStalled es la causa con la que se devuelven los mensajes de un Lane
+// con offsets cuyo lote anterior no se pudo escribir.
+var errLaneStalled = errors.New("an earlier batch of the same lane was not written")
+
+// Pipeline une una fuente con los sinks: reparte los mensajes entre Workers
+// según su Lane, los agrupa en lotes de hasta BatchSize (o lo que haya
+// llegado en FlushInterval), los decodifica, los escribe en los sinks y
+// recién entonces los confirma a la fuente. Un lote que los sinks no aceptan
+// en SinkAttempts intentos, o con un mensaje inválido que la fuente no pudo
+// apartar, se devuelve a la fuente con Nack.
+//
+// Después de un Nack, los mensajes con offsets del mismo Lane ya no se
+// escriben y también se devuelven: aplicarlos movería el offset guardado en
+// los sinks por encima del lote fallido, que se descartaría como ya aplicado.
+type Pipeline struct {
+	Source        Source
+	Sinks         []Sink
+	Workers       int
+	BatchSize     int
+	FlushInterval time.Duration
+	SinkAttempts  int // 0 usa DefaultSinkAttempts
+}
+
+// Run procesa mensajes hasta que ctx termina o la fuente falla. Antes de
+// volver procesa y confirma los lotes que quedaron a medio llenar.
+func (p *Pipeline) Run(ctx context.Context) error {
+	lanes := make([]chan Delivery, p.Workers)
+	var wg sync.WaitGroup
+	for i := range lanes {
+		lanes[i] = make(chan Delivery, p.BatchSize*2)
+		wg.Add(1)
+		go p.worker(ctx, lanes[i], &wg)
+	}
+
+	err := p.Source.Run(ctx, func(d Delivery) {
+		processedCount.Add(1)
+		lanes[d.Lane%p.Workers] <- d
+	})
+
+	log.Println("Waiting for workers to finish...")
+	for _, lane := range lanes {
+		close(lane)
+	}
+	wg.Wait()
+	return err
+}
+
+func (p *Pipeline) worker(ctx context.Context, deliveries <-chan Delivery, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	stalled := make(map[int]bool) // Lanes con offsets que tuvieron un Nack
+	var batch []Delivery
+	ticker := time.NewTicker(p.FlushInterval) // Procesa los lotes incompletos
+	defer ticker.Stop()
+
+	for {
+		select {
+		case d, ok := <-deliveries:
+			if !ok {
+				if len(batch) > 0 {
+					p.process(ctx, batch, stalled)
+				}
+				return
+			}
+			batch = append(batch, d)
+			if len(batch) >= p.BatchSize {
+				p.process(ctx, batch, stalled)
+				batch = nil
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				p.process(ctx, batch, stalled)
+				batch = nil
+			}
+		}
+	}
+}
+
+// process decodifica un lote, rechaza los mensajes inválidos, escribe el lote
+// en los sinks y lo confirma a la fuente, o lo devuelve si los sinks no lo
+// aceptan o si la fuente no pudo rechazar un mensaje: confirmarlo perdería
+// ese mensaje.
+func (p *Pipeline) process(ctx context.Context, deliveries []Delivery, stalled map[int]bool) {
+	var held []Delivery
+	if len(stalled) > 0 {
+		var rest []Delivery
+		for _, d := range deliveries {
+			if d.HasOffset && stalled[d.Lane] {
+				held = append(held, d)
+			} else {
+				rest = append(rest, d)
+			}
+		}
+		p.nack(held, errLaneStalled)
+		if deliveries = rest; len(deliveries) == 0 {
+			return
+		}
+	}
+
+	batch := Batch{Records: make([]Record, len(deliveries)), Now: time.Now()}
+	var rejectErrs []error
+	for i, d := range deliveries {
+		record := Record{Partition: d.Partition, Offset: d.Offset, HasOffset: d.HasOffset}
+		msg, err := DecodeWeatherMessage(d.Body)
+		if err != nil {
+			if d.HasOffset {
+				log.Printf("Failed to decode %s message (partition %d, offset %d): %v", p.Source.Name(), d.Partition, d.Offset, err)
+			} else {
+				log.Printf("Failed to decode %s message: %v", p.Source.Name(), err)
+			}
+			CountError()
+			// Un cuerpo inválido nunca se podrá procesar: la fuente lo aparta
+			// (por ejemplo, a una cola de mensajes muertos) y sólo cuenta en el total
+			if rejectErr := p.Source.Reject(d, err); rejectErr != nil {
+				log.Printf("Failed to reject %s message: %v", p.Source.Name(), rejectErr)
+				CountError()
+				rejectErrs = append(rejectErrs, rejectErr)
+			} else {
+				deliveries[i].Rejected = true
+			}
+		} else {
+			record.Message = msg
+			record.Valid = true
+			log.Printf("Processed message: %+v", msg)
+		}
+		batch.Records[i] = record
+	}
+
+	if len(rejectErrs) > 0 {
+		err := errors.Join(rejectErrs...)
+		log.Printf("Not writing batch of %d messages with unrejected invalid messages: %v", len(deliveries), err)
+		p.fail(deliveries, stalled, err)
+		return
+	}
+
+	attempts := p.SinkAttempts
+	if attempts <= 0 {
+		attempts = DefaultSinkAttempts
+	}
+	if err := ApplyToSinks(ctx, p.Sinks, batch, attempts); err != nil {
+		log.Printf("Giving up on batch of %d messages: %v", len(deliveries), err)
+		p.fail(deliveries, stalled, err)
+		return
+	}
+
+	if err := p.Source.Ack(deliveries); err != nil {
+		log.Printf("Failed to acknowledge batch of %d messages: %v", len(deliveries), err)
+		CountError()
+		return
+	}
+	batchCount.Add(1)
+	log.Printf("Successfully processed batch of %d messages", len(deliveries))
+}
+
+// fail devuelve a la fuente un lote que no se escribió y detiene los Lanes
+// con offsets del lote.
+func (p *Pipeline) fail(deliveries []Delivery, stalled map[int]bool, cause error) {
+	for _, d := range deliveries {
+		if d.HasOffset {
+			stalled[d.Lane] = true
+		}
+	}
+	p.nack(deliveries, cause)
+}
+
+// nack devuelve deliveries a la fuente.
+func (p *Pipeline) nack(deliveries []Delivery, cause error) {
+	if len(deliveries) == 0 {
+		return
+	}
+	if err := p.Source.Nack(deliveries, cause); err != nil {
+		log.Printf("Failed to return batch of %d messages to %s: %v", len(deliveries), p.Source.Name(), err)
+		CountError()
+	}
+}
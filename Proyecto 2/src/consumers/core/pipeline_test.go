@@ -0,0 +1,156 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// testSource registra lo que el Pipeline rechaza, confirma y devuelve.
+type testSource struct {
+	rejectErr error
+
+	rejected []int64
+	acked    []int64
+	nacked   []int64
+	causes   []error
+}
+
+func (s *testSource) Name() string                              { return "test" }
+func (s *testSource) Run(context.Context, func(Delivery)) error { return nil }
+func (s *testSource) Health(context.Context) error              { return nil }
+func (s *testSource) Close() error                              { return nil }
+func (s *testSource) Reject(d Delivery, cause error) error {
+	s.rejected = append(s.rejected, d.Offset)
+	return s.rejectErr
+}
+func (s *testSource) Ack(batch []Delivery) error {
+	s.acked = append(s.acked, offsets(batch)...)
+	return nil
+}
+func (s *testSource) Nack(batch []Delivery, cause error) error {
+	s.nacked = append(s.nacked, offsets(batch)...)
+	s.causes = append(s.causes, cause)
+	return nil
+}
+
+func offsets(batch []Delivery) []int64 {
+	var out []int64
+	for _, d := range batch {
+		out = append(out, d.Offset)
+	}
+	return out
+}
+
+// failingSink falla los primeros failures Apply.
+type failingSink struct {
+	*MemorySink
+	failures int
+}
+
+func (s *failingSink) Apply(ctx context.Context, batch Batch) error {
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("sink unavailable")
+	}
+	return s.MemorySink.Apply(ctx, batch)
+}
+
+func TestPipelineProcess(t *testing.T) {
+	valid := []byte(`{"id":"1","country":"GT","weather":"soleado"}`)
+	invalid := []byte(`{"country":"GT","weather":"granizo"}`)
+	delivery := func(lane int, offset int64, body []byte, hasOffset bool) Delivery {
+		return Delivery{Body: body, Lane: lane, Offset: offset, HasOffset: hasOffset}
+	}
+
+	tests := []struct {
+		name         string
+		rejectErr    error
+		sinkFailures int
+		batches      [][]Delivery
+
+		wantTotal    int64
+		wantCountry  int64 // reportes de GT
+		wantRejected []int64
+		wantAcked    []int64
+		wantNacked   []int64
+		wantStalled  bool // alguna causa de Nack es errLaneStalled
+	}{
+		{
+			name:        "valid batch is written and acked",
+			batches:     [][]Delivery{{delivery(0, 1, valid, true), delivery(0, 2, valid, true)}},
+			wantTotal:   2,
+			wantCountry: 2,
+			wantAcked:   []int64{1, 2},
+		},
+		{
+			name:         "invalid message is rejected and acked with its batch",
+			batches:      [][]Delivery{{delivery(0, 1, invalid, true), delivery(0, 2, valid, true)}},
+			wantTotal:    2,
+			wantCountry:  1,
+			wantRejected: []int64{1},
+			wantAcked:    []int64{1, 2},
+		},
+		{
+			name:         "failed reject returns the batch unwritten",
+			rejectErr:    errors.New("dlq unavailable"),
+			batches:      [][]Delivery{{delivery(0, 1, invalid, true), delivery(0, 2, valid, true)}, {delivery(0, 3, valid, true)}},
+			wantRejected: []int64{1},
+			wantNacked:   []int64{1, 2, 3},
+			wantStalled:  true,
+		},
+		{
+			name:         "sink failure stalls the lane",
+			sinkFailures: 1,
+			batches:      [][]Delivery{{delivery(0, 1, valid, true)}, {delivery(0, 2, valid, true), delivery(1, 7, valid, true)}},
+			wantTotal:    1,
+			wantCountry:  1,
+			wantAcked:    []int64{7},
+			wantNacked:   []int64{1, 2},
+			wantStalled:  true,
+		},
+		{
+			name:         "lanes without offsets are not stalled",
+			sinkFailures: 1,
+			batches:      [][]Delivery{{delivery(0, 0, valid, false)}, {delivery(0, 0, valid, false)}},
+			wantTotal:    1,
+			wantCountry:  1,
+			wantAcked:    []int64{0},
+			wantNacked:   []int64{0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &testSource{rejectErr: tt.rejectErr}
+			sink := &failingSink{MemorySink: NewMemorySink(&Aggregator{}), failures: tt.sinkFailures}
+			p := &Pipeline{Source: source, Sinks: []Sink{sink}, SinkAttempts: 1}
+
+			stalled := make(map[int]bool)
+			for _, batch := range tt.batches {
+				p.process(context.Background(), batch, stalled)
+			}
+
+			snap := sink.Snapshot()
+			if snap.Total != tt.wantTotal || snap.Countries["GT"] != tt.wantCountry {
+				t.Errorf("sink total %d, GT %d; want %d, %d", snap.Total, snap.Countries["GT"], tt.wantTotal, tt.wantCountry)
+			}
+			if !reflect.DeepEqual(source.rejected, tt.wantRejected) {
+				t.Errorf("rejected = %v, want %v", source.rejected, tt.wantRejected)
+			}
+			if !reflect.DeepEqual(source.acked, tt.wantAcked) {
+				t.Errorf("acked = %v, want %v", source.acked, tt.wantAcked)
+			}
+			if !reflect.DeepEqual(source.nacked, tt.wantNacked) {
+				t.Errorf("nacked = %v, want %v", source.nacked, tt.wantNacked)
+			}
+			stalledCause := false
+			for _, cause := range source.causes {
+				stalledCause = stalledCause || errors.Is(cause, errLaneStalled)
+			}
+			if stalledCause != tt.wantStalled {
+				t.Errorf("nacked as stalled = %v, want %v (causes %v)", stalledCause, tt.wantStalled, source.causes)
+			}
+		})
+	}
+}
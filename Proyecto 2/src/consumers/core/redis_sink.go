@@ -0,0 +1,230 @@
+package core
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Claves de los contadores en Redis o Valkey.
+const (
+	CountryHash        = "country_counts"         // país -> reportes
+	TotalKey           = "total_messages"         // reportes de todos los países, incluidos los inválidos
+	CountryWeatherHash = "country_weather_counts" // "<país>:<clima>" -> reportes
+	WeatherHash        = "weather_counts"         // clima -> reportes de todos los países
+	TopWeatherHash     = "country_top_weather"    // país -> clima más común
+	WindowLateHash     = "window_late_events"     // granularidad -> reportes que llegaron con su ventana expirada
+	OffsetsHash        = "kafka_applied_offsets"  // partición -> último offset aplicado
+)
+
+// applyBatchScript aplica un lote a los contadores y guarda el último offset
+// aplicado por partición en la misma ejecución atómica. Los mensajes con un
+// offset ya aplicado se ignoran, así reprocesar un lote tras una caída no
+// vuelve a sumar; los mensajes sin partición (RabbitMQ) siempre se aplican.
+// Devuelve las posiciones (desde 0) de los mensajes aplicados.
+//
+// addWeather mantiene los agregados por clima. Los contadores sólo crecen,
+// así que el clima más común de un país sólo puede cambiar por el que se
+// acaba de sumar; en caso de empate se mantiene el anterior.
+//
+// KEYS: hash de países, contador total, hash de offsets, hash de pares
+// (país, clima), hash de climas, hash del clima más común por país, hash de
+// reportes atrasados y luego las ventanas del lote (ver windowBatch).
+// ARGV: la cantidad de ventanas W, la expiración de cada una y luego, por
+// mensaje, (partición, offset, país, clima, ventanas, granularidades en las
+// que llegó tarde). Un país vacío sólo cuenta en el total.
+var applyBatchScript = redis.NewScript(`
+local function addWeather(pairsKey, weatherKey, topKey, country, weather, n)
+	local count = redis.call('HINCRBY', pairsKey, country .. ':' .. weather, n)
+	redis.call('HINCRBY', weatherKey, weather, n)
+	local top = redis.call('HGET', topKey, country)
+	if top == weather then
+		return
+	end
+	if not top or count > tonumber(redis.call('HGET', pairsKey, country .. ':' .. top) or '0') then
+		redis.call('HSET', topKey, country, weather)
+	end
+end
+
+local windowCount = tonumber(ARGV[1])
+local applied = {}
+local counts = {}
+local weathers = {}
+local windowCounts = {}
+local lateCounts = {}
+local total = 0
+local appliedIndexes = {}
+for i = windowCount + 2, #ARGV, 6 do
+	local partition = ARGV[i]
+	local offset = tonumber(ARGV[i + 1])
+	local country = ARGV[i + 2]
+	local weather = ARGV[i + 3]
+	local apply = true
+	if partition ~= '' then
+		local last = applied[partition]
+		if last == nil then
+			last = tonumber(redis.call('HGET', KEYS[3], partition) or '-1')
+		end
+		apply = offset > last
+		if apply then
+			applied[partition] = offset
+		else
+			applied[partition] = last
+		end
+	end
+	if apply then
+		total = total + 1
+		appliedIndexes[total] = (i - windowCount - 2) / 6
+		if country ~= '' then
+			counts[country] = (counts[country] or 0) + 1
+			weathers[country] = weathers[country] or {}
+			weathers[country][weather] = (weathers[country][weather] or 0) + 1
+			for value in string.gmatch(ARGV[i + 4], '[^,]+') do
+				local slot = tonumber(value)
+				windowCounts[slot] = windowCounts[slot] or {}
+				windowCounts[slot][country] = (windowCounts[slot][country] or 0) + 1
+			end
+			for granularity in string.gmatch(ARGV[i + 5], '[^,]+') do
+				lateCounts[granularity] = (lateCounts[granularity] or 0) + 1
+			end
+		end
+	end
+end
+for country, n in pairs(counts) do
+	redis.call('HINCRBY', KEYS[1], country, n)
+	for weather, m in pairs(weathers[country]) do
+		addWeather(KEYS[4], KEYS[5], KEYS[6], country, weather, m)
+	end
+end
+for slot, countries in pairs(windowCounts) do
+	for country, n in pairs(countries) do
+		redis.call('HINCRBY', KEYS[7 + slot], country, n)
+		redis.call('EXPIREAT', KEYS[7 + slot], ARGV[1 + slot])
+	end
+end
+for granularity, n in pairs(lateCounts) do
+	redis.call('HINCRBY', KEYS[7], granularity, n)
+end
+if total > 0 then
+	redis.call('INCRBY', KEYS[2], total)
+end
+for partition, offset in pairs(applied) do
+	redis.call('HSET', KEYS[3], partition, offset)
+end
+return appliedIndexes
+`)
+
+// redisSink mantiene los contadores en Redis o Valkey con applyBatchScript y,
+// si EVENT_STREAM está definido, agrega los reportes al stream de auditoría.
+type redisSink struct {
+	name   string
+	client *redis.Client
+	agg    *Aggregator
+	stream *eventStream
+}
+
+func newRedisSink(name, addr string, agg *Aggregator) *redisSink {
+	client := redis.NewClient(&redis.Options{
+		Addr:         addr,
+		MinIdleConns: 5,
+		PoolSize:     20,
+		PoolTimeout:  30 * time.Second,
+	})
+	return &redisSink{name: name, client: client, agg: agg, stream: newEventStreamFromEnv(client)}
+}
+
+func (s *redisSink) Name() string { return s.name }
+
+// Apply aplica el lote a los contadores junto con el último offset de cada
+// partición en una sola ejecución atómica, así un lote repetido no vuelve a
+// sumar.
+func (s *redisSink) Apply(ctx context.Context, batch Batch) error {
+	windows := newWindowBatch()
+	args := []interface{}{nil} // la cantidad de ventanas se conoce al final
+	var messages []interface{}
+
+	for _, record := range batch.Records {
+		partition, country, weather, slots, late := "", "", "", "", ""
+		if record.HasOffset {
+			partition = strconv.Itoa(int(record.Partition))
+		}
+		if record.Valid {
+			tally := s.agg.Tally(record.Message, batch.Now)
+			country, weather = tally.Country, string(tally.Weather)
+			slots = joinSlots(windows.add(tally.Windows))
+			late = strings.Join(tally.Late, ",")
+			if len(tally.Late) > 0 {
+				log.Printf("Late message %s (event time %v): not counted in %v windows of %s", record.Message.ID, record.Message.EventTime, tally.Late, s.name)
+			}
+		}
+		messages = append(messages, partition, record.Offset, country, weather, slots, late)
+	}
+
+	args[0] = len(windows.keys)
+	args = append(args, windows.expireAt...)
+	args = append(args, messages...)
+	keys := []string{CountryHash, TotalKey, OffsetsHash, CountryWeatherHash, WeatherHash, TopWeatherHash, WindowLateHash}
+	keys = append(keys, windows.keys...)
+
+	applied, err := applyBatchScript.Run(ctx, s.client, keys, args...).Int64Slice()
+	if err != nil {
+		return err
+	}
+	if skipped := len(batch.Records) - len(applied); skipped > 0 {
+		log.Printf("Skipped %d messages already applied to %s", skipped, s.name)
+	}
+
+	// Al stream sólo van los mensajes que el script aplicó, así un lote
+	// repetido tampoco duplica entradas
+	var decoded []WeatherMessage
+	for _, i := range applied {
+		if record := batch.Records[i]; record.Valid {
+			decoded = append(decoded, record.Message)
+		}
+	}
+
+	// El stream de auditoría es de mejor esfuerzo: un fallo no detiene el lote
+	if err := s.stream.append(ctx, decoded); err != nil {
+		log.Printf("Failed to append batch to event stream in %s: %v", s.name, err)
+		CountError()
+	}
+	return nil
+}
+
+// Flush no hace nada: Apply ya escribió el lote.
+func (s *redisSink) Flush(context.Context) error { return nil }
+
+func (s *redisSink) Health(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
+
+func (s *redisSink) Close() error { return s.client.Close() }
+
+// AppliedOffsets lee el último offset aplicado de cada partición.
+func (s *redisSink) AppliedOffsets(ctx context.Context, partitions []int32) (map[int32]int64, error) {
+	fields := make([]string, len(partitions))
+	for i, partition := range partitions {
+		fields[i] = strconv.Itoa(int(partition))
+	}
+
+	values, err := s.client.HMGet(ctx, OffsetsHash, fields...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int32]int64, len(partitions))
+	for i, partition := range partitions {
+		if value, ok := values[i].(string); ok {
+			offset, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			applied[partition] = offset
+		}
+	}
+	return applied, nil
+}
@@ -0,0 +1,174 @@
+package core
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// redisTestAddrEnv apunta a un Redis o Valkey de pruebas; los tests vacían su
+// base de datos, así que no debe ser uno con datos.
+const redisTestAddrEnv = "REDIS_TEST_ADDR"
+
+func newTestRedisSink(t *testing.T) *redisSink {
+	t.Helper()
+	addr := os.Getenv(redisTestAddrEnv)
+	if addr == "" {
+		t.Skipf("%s not set", redisTestAddrEnv)
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { client.Close() })
+	if err := client.FlushDB(context.Background()).Err(); err != nil {
+		t.Fatalf("flush %s: %v", addr, err)
+	}
+	return &redisSink{
+		name:   "redis",
+		client: client,
+		agg:    &Aggregator{},
+		stream: &eventStream{client: client, key: "test-events", maxLen: 1000},
+	}
+}
+
+func offsetRecord(partition int32, offset int64, country string) Record {
+	return Record{
+		Message:   WeatherMessage{Country: country, Weather: WeatherSoleado},
+		Valid:     true,
+		Partition: partition,
+		Offset:    offset,
+		HasOffset: true,
+	}
+}
+
+func TestRedisSinkSkipsAppliedOffsets(t *testing.T) {
+	tests := []struct {
+		name        string
+		batches     [][]Record
+		wantTotal   int64
+		wantCountry map[string]int64
+		wantOffsets map[int32]int64
+		wantStream  int64 // entradas en el stream de auditoría
+	}{
+		{
+			name: "replayed batch",
+			batches: [][]Record{
+				{offsetRecord(0, 0, "GT"), offsetRecord(0, 1, "GT")},
+				{offsetRecord(0, 0, "GT"), offsetRecord(0, 1, "GT")},
+			},
+			wantTotal:   2,
+			wantCountry: map[string]int64{"GT": 2},
+			wantOffsets: map[int32]int64{0: 1},
+			wantStream:  2,
+		},
+		{
+			name: "overlapping batch",
+			batches: [][]Record{
+				{offsetRecord(0, 0, "GT"), offsetRecord(0, 1, "GT")},
+				{offsetRecord(0, 1, "GT"), offsetRecord(0, 2, "SV")},
+			},
+			wantTotal:   3,
+			wantCountry: map[string]int64{"GT": 2, "SV": 1},
+			wantOffsets: map[int32]int64{0: 2},
+			wantStream:  3,
+		},
+		{
+			name: "partitions are independent",
+			batches: [][]Record{
+				{offsetRecord(0, 5, "GT")},
+				{offsetRecord(1, 3, "SV"), offsetRecord(0, 5, "GT")},
+			},
+			wantTotal:   2,
+			wantCountry: map[string]int64{"GT": 1, "SV": 1},
+			wantOffsets: map[int32]int64{0: 5, 1: 3},
+			wantStream:  2,
+		},
+		{
+			name: "duplicate offset in the same batch",
+			batches: [][]Record{
+				{offsetRecord(0, 0, "GT"), offsetRecord(0, 0, "GT")},
+			},
+			wantTotal:   1,
+			wantCountry: map[string]int64{"GT": 1},
+			wantOffsets: map[int32]int64{0: 0},
+			wantStream:  1,
+		},
+		{
+			name: "invalid records count once in the total",
+			batches: [][]Record{
+				{{Partition: 0, Offset: 0, HasOffset: true}, offsetRecord(0, 1, "GT")},
+				{{Partition: 0, Offset: 0, HasOffset: true}},
+			},
+			wantTotal:   2,
+			wantCountry: map[string]int64{"GT": 1},
+			wantOffsets: map[int32]int64{0: 1},
+			wantStream:  1,
+		},
+		{
+			name: "records without offsets are always applied",
+			batches: [][]Record{
+				{{Message: WeatherMessage{Country: "GT", Weather: WeatherNubloso}, Valid: true}},
+				{{Message: WeatherMessage{Country: "GT", Weather: WeatherNubloso}, Valid: true}},
+			},
+			wantTotal:   2,
+			wantCountry: map[string]int64{"GT": 2},
+			wantOffsets: map[int32]int64{},
+			wantStream:  2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := newTestRedisSink(t)
+			ctx := context.Background()
+			for _, records := range tt.batches {
+				if err := sink.Apply(ctx, Batch{Records: records, Now: time.Now()}); err != nil {
+					t.Fatalf("Apply: %v", err)
+				}
+			}
+
+			total, err := sink.client.Get(ctx, TotalKey).Int64()
+			if err != nil {
+				t.Fatalf("get %s: %v", TotalKey, err)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("total = %d, want %d", total, tt.wantTotal)
+			}
+
+			countries, err := sink.client.HGetAll(ctx, CountryHash).Result()
+			if err != nil {
+				t.Fatalf("get %s: %v", CountryHash, err)
+			}
+			if len(countries) != len(tt.wantCountry) {
+				t.Errorf("countries = %v, want %v", countries, tt.wantCountry)
+			}
+			for country, want := range tt.wantCountry {
+				got, err := sink.client.HGet(ctx, CountryHash, country).Int64()
+				if err != nil || got != want {
+					t.Errorf("country %s = %d (%v), want %d", country, got, err, want)
+				}
+			}
+
+			offsets, err := sink.AppliedOffsets(ctx, []int32{0, 1})
+			if err != nil {
+				t.Fatalf("AppliedOffsets: %v", err)
+			}
+			if len(offsets) != len(tt.wantOffsets) {
+				t.Errorf("offsets = %v, want %v", offsets, tt.wantOffsets)
+			}
+			for partition, want := range tt.wantOffsets {
+				if got := offsets[partition]; got != want {
+					t.Errorf("offset of partition %d = %d, want %d", partition, got, want)
+				}
+			}
+
+			entries, err := sink.client.XLen(ctx, sink.stream.key).Result()
+			if err != nil {
+				t.Fatalf("XLEN %s: %v", sink.stream.key, err)
+			}
+			if entries != tt.wantStream {
+				t.Errorf("stream entries = %d, want %d", entries, tt.wantStream)
+			}
+		})
+	}
+}
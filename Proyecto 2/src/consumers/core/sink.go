@@ -0,0 +1,205 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Variables de entorno de los sinks.
+const (
+	SinksEnv        = "SINKS"
+	SinkFilePathEnv = "SINK_FILE_PATH"
+	RedisAddrEnv    = "REDIS_ADDR"
+	ValkeyAddrEnv   = "VALKEY_ADDR"
+)
+
+// Sink es un destino de los reportes que procesa el consumidor. Apply guarda
+// un lote, Flush lo hace durable antes de que el consumidor confirme los
+// mensajes al broker y Health informa si el destino está disponible. Un sink
+// puede recibir dos veces el mismo lote si el consumidor se reinicia antes de
+// confirmarlo.
+type Sink interface {
+	Name() string
+	Apply(ctx context.Context, batch Batch) error
+	Flush(ctx context.Context) error
+	Health(ctx context.Context) error
+	Close() error
+}
+
+// Batch es un lote de mensajes leídos del broker.
+type Batch struct {
+	Records []Record
+	Now     time.Time // momento en que se procesa, para ubicar los reportes en las ventanas
+}
+
+// Record es un mensaje del lote.
+type Record struct {
+	Message WeatherMessage
+	Valid   bool // false si el cuerpo no se pudo decodificar: sólo cuenta en el total
+
+	// Partition y Offset ubican el mensaje en el broker; sólo son válidos si
+	// HasOffset es true (Kafka).
+	Partition int32
+	Offset    int64
+	HasOffset bool
+}
+
+// NewSinksFromEnv crea los sinks listados en SINKS, separados por comas, o en
+// defaults si SINKS no está definido:
+//   - "redis": contadores en REDIS_ADDR;
+//   - "valkey": contadores en VALKEY_ADDR;
+//   - "file": archivo NDJSON en SINK_FILE_PATH;
+//   - "memory": contadores en memoria, para pruebas.
+func NewSinksFromEnv(defaults string, agg *Aggregator) []Sink {
+	var sinks []Sink
+	for _, name := range strings.Split(GetEnv(SinksEnv, defaults), ",") {
+		switch name = strings.TrimSpace(name); name {
+		case "":
+			continue
+		case "redis":
+			sinks = append(sinks, newRedisSink(name, GetEnv(RedisAddrEnv, "redis-service:6379"), agg))
+		case "valkey":
+			sinks = append(sinks, newRedisSink(name, GetEnv(ValkeyAddrEnv, "valkey:6379"), agg))
+		case "file":
+			sink, err := newFileSink(GetEnv(SinkFilePathEnv, "/var/lib/weather/reports.ndjson"))
+			if err != nil {
+				log.Fatalf("Failed to open file sink: %v", err)
+			}
+			sinks = append(sinks, sink)
+		case "memory":
+			sinks = append(sinks, NewMemorySink(agg))
+		default:
+			log.Fatalf("Unknown sink %q in SINKS", name)
+		}
+		log.Printf("Writing reports to sink %s", name)
+	}
+	if len(sinks) == 0 {
+		log.Fatalf("SINKS does not list any sink")
+	}
+	return sinks
+}
+
+// DefaultSinkAttempts es la cantidad de intentos por lote si el Pipeline no
+// indica otra.
+const DefaultSinkAttempts = 5
+
+// ApplyToSinks aplica el lote a cada sink y lo hace durable con Flush. Los
+// sinks que fallan se reintentan con backoff, hasta attempts intentos o hasta
+// que ctx termina; los que ya lo aceptaron no lo vuelven a recibir. Devuelve
+// el error de los sinks que no lo aceptaron.
+//
+// Cada intento usa un contexto que no se cancela con ctx: al apagarse, el
+// consumidor todavía intenta una vez escribir los lotes que ya leyó.
+func ApplyToSinks(ctx context.Context, sinks []Sink, batch Batch, attempts int) error {
+	opCtx := context.WithoutCancel(ctx)
+	applied := make(map[Sink]bool, len(sinks))
+	pending := sinks
+	retryDelay := 500 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		var failed []Sink
+		var errs []error
+		for _, sink := range pending {
+			var err error
+			if !applied[sink] {
+				err = sink.Apply(opCtx, batch)
+				applied[sink] = err == nil
+			}
+			if err == nil {
+				err = sink.Flush(opCtx)
+			}
+			if err != nil {
+				log.Printf("Failed to write batch to sink %s (attempt %d/%d): %v", sink.Name(), attempt, attempts, err)
+				CountError()
+				failed = append(failed, sink)
+				errs = append(errs, fmt.Errorf("sink %s: %w", sink.Name(), err))
+			}
+		}
+		if len(failed) == 0 {
+			return nil
+		}
+		if attempt >= attempts {
+			return errors.Join(errs...)
+		}
+		pending = failed
+
+		select {
+		case <-time.After(retryDelay):
+		case <-ctx.Done():
+			return fmt.Errorf("%w (stopped retrying: %v)", errors.Join(errs...), context.Cause(ctx))
+		}
+		if retryDelay < 10*time.Second {
+			retryDelay *= 2
+		}
+	}
+}
+
+// CheckSinks verifica que todos los sinks estén disponibles.
+func CheckSinks(ctx context.Context, sinks []Sink) error {
+	for _, sink := range sinks {
+		if err := sink.Health(ctx); err != nil {
+			return fmt.Errorf("sink %s is not available: %w", sink.Name(), err)
+		}
+	}
+	return nil
+}
+
+// CloseSinks vacía y cierra los sinks al terminar.
+func CloseSinks(sinks []Sink) {
+	for _, sink := range sinks {
+		if err := sink.Flush(context.Background()); err != nil {
+			log.Printf("Failed to flush sink %s: %v", sink.Name(), err)
+		}
+		if err := sink.Close(); err != nil {
+			log.Printf("Failed to close sink %s: %v", sink.Name(), err)
+		}
+	}
+}
+
+// OffsetSink es un sink que recuerda el último offset aplicado por partición
+// y descarta los mensajes que ya aplicó. AppliedOffsets omite las particiones
+// de las que no tiene registro.
+type OffsetSink interface {
+	AppliedOffsets(ctx context.Context, partitions []int32) (map[int32]int64, error)
+}
+
+// ResumeOffsets devuelve, por partición, el offset desde el que hay que
+// reanudar: el siguiente al más atrasado entre los sinks que recuerdan
+// offsets, así ninguno pierde mensajes; los demás descartan los que ya
+// tienen. Una partición queda fuera del resultado si algún sink no tiene
+// registro de ella o ninguno recuerda offsets; en ese caso se reanuda desde el
+// offset confirmado en el broker, que nunca supera al de ningún sink.
+func ResumeOffsets(ctx context.Context, sinks []Sink, partitions []int32) (map[int32]int64, error) {
+	var resumed map[int32]int64
+	for _, sink := range sinks {
+		offsets, ok := sink.(OffsetSink)
+		if !ok {
+			continue
+		}
+		applied, err := offsets.AppliedOffsets(ctx, partitions)
+		if err != nil {
+			return nil, err
+		}
+		if resumed == nil {
+			resumed = applied
+			continue
+		}
+		for partition, offset := range resumed {
+			other, ok := applied[partition]
+			if !ok {
+				delete(resumed, partition)
+			} else if other < offset {
+				resumed[partition] = other
+			}
+		}
+	}
+
+	next := make(map[int32]int64, len(resumed))
+	for partition, offset := range resumed {
+		next[partition] = offset + 1
+	}
+	return next, nil
+}
@@ -0,0 +1,56 @@
+package core
+
+import "context"
+
+// Delivery es un mensaje leído de un broker.
+type Delivery struct {
+	Body []byte
+
+	// Lane elige el worker que procesa el mensaje: los mensajes con el mismo
+	// Lane se procesan en orden y por el mismo worker (por ejemplo, la
+	// partición de Kafka o el canal de RabbitMQ).
+	Lane int
+
+	// Partition y Offset ubican el mensaje para los sinks que descartan los
+	// mensajes que ya aplicaron (ver OffsetSink). HasOffset es false si el
+	// broker no tiene offsets.
+	Partition int32
+	Offset    int64
+	HasOffset bool
+
+	// Rejected indica que el Pipeline ya rechazó el mensaje con Source.Reject.
+	Rejected bool
+
+	// Ref es el mensaje original del broker, para que la fuente lo confirme.
+	Ref any
+}
+
+// Source es un broker del que el consumidor lee reportes.
+type Source interface {
+	Name() string
+
+	// Run lee mensajes y los entrega con deliver, que puede llamarse desde
+	// varias goroutines, hasta que ctx termina (devuelve nil) o la fuente
+	// falla sin remedio.
+	Run(ctx context.Context, deliver func(Delivery)) error
+
+	// Reject aparta un mensaje que no se puede decodificar; cause es el error
+	// de decodificación. Si falla, el lote no se escribe y se devuelve con
+	// Nack, sin el mensaje entre los rechazados.
+	Reject(d Delivery, cause error) error
+
+	// Ack confirma un lote que ya escribieron todos los sinks. Los mensajes
+	// rechazados también forman parte del lote, con Rejected en true.
+	Ack(batch []Delivery) error
+
+	// Nack devuelve a la fuente un lote que los sinks no aceptaron; cause es
+	// el error de los sinks. La fuente decide si lo vuelve a entregar más
+	// tarde. Los mensajes con Rejected en true ya no le pertenecen al lote y
+	// no se devuelven.
+	Nack(batch []Delivery, cause error) error
+
+	Health(ctx context.Context) error
+
+	// Close libera la fuente una vez procesados los últimos lotes.
+	Close() error
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// eventStream agrega cada reporte decodificado a un Redis Stream acotado para
+// poder auditar los reportes recientes (XREVRANGE, XREAD). Es opcional: se
+// activa con EVENT_STREAM (nombre del stream) y EVENT_STREAM_MAXLEN fija su
+// largo máximo aproximado. Sólo recibe los reportes que applyBatchScript
+// aplicó, así un lote de Kafka repetido no se duplica; aun así es una
+// bitácora, no un registro exacto: un reporte sin offset (RabbitMQ) que se
+// reentrega puede aparecer dos veces con el mismo id.
+type eventStream struct {
+	client *redis.Client
+	key    string
+	maxLen int64
+}
+
+// newEventStreamFromEnv devuelve nil si EVENT_STREAM no está definido.
+func newEventStreamFromEnv(client *redis.Client) *eventStream {
+	key := GetEnv("EVENT_STREAM", "")
+	if key == "" {
+		return nil
+	}
+	maxLen, err := strconv.ParseInt(GetEnv("EVENT_STREAM_MAXLEN", "100000"), 10, 64)
+	if err != nil || maxLen < 1 {
+		log.Fatalf("Invalid EVENT_STREAM_MAXLEN: %q", GetEnv("EVENT_STREAM_MAXLEN", ""))
+	}
+	log.Printf("Appending decoded reports to stream %s (max length ~%d)", key, maxLen)
+	return &eventStream{client: client, key: key, maxLen: maxLen}
+}
+
+// append agrega los reportes al stream en un solo pipeline; con el stream
+// desactivado no hace nada.
+func (s *eventStream) append(ctx context.Context, msgs []WeatherMessage) error {
+	if s == nil || len(msgs) == 0 {
+		return nil
+	}
+	pipe := s.client.Pipeline()
+	for _, msg := range msgs {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: s.key,
+			MaxLen: s.maxLen,
+			Approx: true,
+			Values: streamValues(msg),
+		})
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// streamValues son los campos de la entrada del stream; los tiempos van en
+// RFC 3339 y los campos vacíos se omiten.
+func streamValues(msg WeatherMessage) []interface{} {
+	values := []interface{}{
+		"id", msg.ID,
+		"description", msg.Description,
+		"country", msg.Country,
+		"weather", string(msg.Weather),
+	}
+	if !msg.EventTime.IsZero() {
+		values = append(values, "event_time", msg.EventTime.Format(time.RFC3339Nano))
+	}
+	if !msg.IngestedAt.IsZero() {
+		values = append(values, "ingested_at", msg.IngestedAt.Format(time.RFC3339Nano))
+	}
+	if msg.Source != "" {
+		values = append(values, "source", msg.Source)
+	}
+	return values
+}
@@ -0,0 +1,190 @@
+// kafka-dlq inspecciona la DLQ del consumidor de Kafka y reenvía sus mensajes
+// al topic de origen.
+//
+//	kafka-dlq inspect [-limit N]           lista los mensajes de la DLQ sin consumirlos
+//	kafka-dlq redrive [-limit N] [-group G] reenvía los mensajes aún no reenviados
+//
+// redrive recuerda hasta dónde reenvió con los offsets del grupo G, así cada
+// mensaje se reenvía una sola vez; no hay que correr dos redrive del mismo
+// grupo a la vez. Un mensaje reenviado que vuelve a fallar regresa a la DLQ
+// con el header dlq.redrives incrementado. Si un reenvío falla, redrive se
+// detiene con los anteriores ya confirmados y termina con código 1.
+//
+// Usa KAFKA_BOOTSTRAP_SERVERS y KAFKA_DLQ_TOPIC, igual que el consumidor.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"consumer-core"
+	"consumer-kafka/internal/dlq"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+const (
+	kafkaTopic  = "weather-tweets" // topic al que se reenvía si el mensaje no indica su origen
+	idleTimeout = 10 * time.Second // se deja de leer si no llega nada en este tiempo
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s inspect [-limit N] | redrive [-limit N] [-group G]\n", os.Args[0])
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	brokers := core.GetEnv("KAFKA_BOOTSTRAP_SERVERS", "kafka-service:9092")
+	topic := core.GetEnv("KAFKA_DLQ_TOPIC", dlq.DefaultTopic)
+
+	flags := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	limit := flags.Int("limit", 0, "maximum number of messages (0 means all)")
+	switch os.Args[1] {
+	case "inspect":
+		flags.Parse(os.Args[2:])
+		inspect(brokers, topic, *limit)
+	case "redrive":
+		group := flags.String("group", "kafka-dlq-redrive", "consumer group that remembers what was already re-driven")
+		flags.Parse(os.Args[2:])
+		if err := redrive(brokers, topic, *group, *limit); err != nil {
+			log.Printf("Redrive stopped: %v", err)
+			os.Exit(1)
+		}
+	default:
+		usage()
+	}
+}
+
+// inspect imprime los mensajes de la DLQ desde el principio sin confirmar
+// offsets.
+func inspect(brokers, topic string, limit int) {
+	consumer := newConsumer(brokers, "kafka-dlq-inspect", topic, kafka.OffsetBeginning)
+	defer consumer.Close()
+
+	count, err := readAll(consumer, limit, func(msg *kafka.Message) error {
+		fmt.Printf("partition %d offset %v at %s\n", msg.TopicPartition.Partition, msg.TopicPartition.Offset, dlq.Header(msg, dlq.HeaderTimestamp))
+		fmt.Printf("  error:    %s\n", dlq.Header(msg, dlq.HeaderError))
+		fmt.Printf("  source:   %s [%s] offset %s\n", dlq.Header(msg, dlq.HeaderTopic), dlq.Header(msg, dlq.HeaderPartition), dlq.Header(msg, dlq.HeaderOffset))
+		if redrives := dlq.Header(msg, dlq.HeaderRedrives); redrives != "" {
+			fmt.Printf("  redrives: %s\n", redrives)
+		}
+		fmt.Printf("  key:      %s\n", msg.Key)
+		fmt.Printf("  value:    %s\n", msg.Value)
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to inspect %s: %v", topic, err)
+	}
+	log.Printf("Inspected %d messages in %s", count, topic)
+}
+
+// redrive reenvía cada mensaje a su topic de origen y confirma su offset en
+// el grupo después de que el broker acepta el reenvío. Se detiene en el
+// primer mensaje que no pudo reenviar o confirmar: los anteriores quedan
+// confirmados y el siguiente redrive continúa desde ese mensaje.
+func redrive(brokers, topic, group string, limit int) error {
+	consumer := newConsumer(brokers, group, topic, kafka.OffsetStored)
+	defer consumer.Close()
+
+	producer, err := kafka.NewProducer(&kafka.ConfigMap{
+		"bootstrap.servers": brokers,
+		"client.id":         "kafka-dlq",
+		"acks":              "all",
+	})
+	if err != nil {
+		log.Fatalf("Failed to create Kafka producer: %v", err)
+	}
+	defer producer.Close()
+
+	count, err := readAll(consumer, limit, func(msg *kafka.Message) error {
+		out := dlq.Redrive(msg, kafkaTopic)
+		deliveryChan := make(chan kafka.Event, 1)
+		if err := producer.Produce(out, deliveryChan); err != nil {
+			return fmt.Errorf("re-drive message at partition %d offset %v: %w", msg.TopicPartition.Partition, msg.TopicPartition.Offset, err)
+		}
+		if err := (<-deliveryChan).(*kafka.Message).TopicPartition.Error; err != nil {
+			return fmt.Errorf("re-drive message at partition %d offset %v: %w", msg.TopicPartition.Partition, msg.TopicPartition.Offset, err)
+		}
+		// Si el commit falla, el siguiente redrive vuelve a reenviar este mensaje
+		if _, err := consumer.CommitMessage(msg); err != nil {
+			return fmt.Errorf("commit partition %d offset %v: %w", msg.TopicPartition.Partition, msg.TopicPartition.Offset, err)
+		}
+		log.Printf("Re-drove message at partition %d offset %v to %s", msg.TopicPartition.Partition, msg.TopicPartition.Offset, *out.TopicPartition.Topic)
+		return nil
+	})
+	log.Printf("Re-drove %d messages from %s", count, topic)
+	return err
+}
+
+// newConsumer asigna todas las particiones de topic desde start.
+func newConsumer(brokers, group, topic string, start kafka.Offset) *kafka.Consumer {
+	consumer, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers":    brokers,
+		"group.id":             group,
+		"auto.offset.reset":    "earliest",
+		"enable.auto.commit":   false,
+		"enable.partition.eof": true,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create Kafka consumer: %v", err)
+	}
+
+	metadata, err := consumer.GetMetadata(&topic, false, 5000)
+	if err != nil {
+		log.Fatalf("Failed to get metadata of topic %s: %v", topic, err)
+	}
+	var partitions []kafka.TopicPartition
+	for _, p := range metadata.Topics[topic].Partitions {
+		partitions = append(partitions, kafka.TopicPartition{Topic: &topic, Partition: p.ID, Offset: start})
+	}
+	if len(partitions) == 0 {
+		log.Fatalf("Topic %s has no partitions", topic)
+	}
+	if err := consumer.Assign(partitions); err != nil {
+		log.Fatalf("Failed to assign topic %s: %v", topic, err)
+	}
+	return consumer
+}
+
+// readAll llama a handle con cada mensaje hasta llegar al final de todas las
+// particiones asignadas, a limit mensajes o a un error de handle, y devuelve
+// cuántos mensajes procesó handle sin error.
+func readAll(consumer *kafka.Consumer, limit int, handle func(*kafka.Message) error) (int, error) {
+	assigned, err := consumer.Assignment()
+	if err != nil {
+		return 0, fmt.Errorf("get assignment: %w", err)
+	}
+	pending := make(map[int32]bool, len(assigned))
+	for _, tp := range assigned {
+		pending[tp.Partition] = true
+	}
+
+	count := 0
+	idleSince := time.Now()
+	for len(pending) > 0 && (limit <= 0 || count < limit) {
+		switch e := consumer.Poll(500).(type) {
+		case *kafka.Message:
+			if err := handle(e); err != nil {
+				return count, err
+			}
+			count++
+			idleSince = time.Now()
+		case kafka.PartitionEOF:
+			delete(pending, e.Partition)
+		case kafka.Error:
+			log.Printf("Consumer error: %v", e)
+		case nil:
+			if time.Since(idleSince) > idleTimeout {
+				log.Printf("No messages for %v, stopping", idleTimeout)
+				return count, nil
+			}
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"consumer-core"
+	"consumer-kafka/internal/dlq"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// defaultDLQAttempts es la cantidad de intentos por defecto para publicar un
+// mensaje en la DLQ.
+const defaultDLQAttempts = 5
+
+// dlqWriter publica en la DLQ los mensajes que no se pueden decodificar.
+type dlqWriter struct {
+	producer *kafka.Producer
+	topic    string
+	attempts int
+}
+
+func newDLQWriter(brokers, topic string, attempts int) (*dlqWriter, error) {
+	producer, err := kafka.NewProducer(&kafka.ConfigMap{
+		"bootstrap.servers":  brokers,
+		"client.id":          "kafka-consumer-dlq",
+		"acks":               "all",
+		"message.timeout.ms": 10000,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if attempts <= 0 {
+		attempts = defaultDLQAttempts
+	}
+	return &dlqWriter{producer: producer, topic: topic, attempts: attempts}, nil
+}
+
+// send publica msg en la DLQ y espera la confirmación del broker. Reintenta
+// con backoff hasta attempts veces; si no lo logra devuelve el error y el
+// offset de msg no se debe confirmar en el topic de origen, o el mensaje se
+// perdería.
+func (w *dlqWriter) send(msg *kafka.Message, cause error) error {
+	retryDelay := 500 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		err := w.produce(dlq.Wrap(msg, w.topic, cause, time.Now()))
+		if err == nil {
+			log.Printf("Sent message at offset %v of partition %d to %s: %v", msg.TopicPartition.Offset, msg.TopicPartition.Partition, w.topic, cause)
+			return nil
+		}
+		log.Printf("Failed to send message at offset %v to %s (attempt %d/%d): %v", msg.TopicPartition.Offset, w.topic, attempt, w.attempts, err)
+		core.CountError()
+		if attempt >= w.attempts {
+			return fmt.Errorf("send message at offset %v to %s: %w", msg.TopicPartition.Offset, w.topic, err)
+		}
+		time.Sleep(retryDelay)
+		if retryDelay < 10*time.Second {
+			retryDelay *= 2
+		}
+	}
+}
+
+func (w *dlqWriter) produce(msg *kafka.Message) error {
+	deliveryChan := make(chan kafka.Event, 1)
+	if err := w.producer.Produce(msg, deliveryChan); err != nil {
+		return err
+	}
+	return (<-deliveryChan).(*kafka.Message).TopicPartition.Error
+}
+
+// close espera a que se entreguen los mensajes pendientes y cierra el productor.
+func (w *dlqWriter) close() {
+	w.producer.Flush(5000)
+	w.producer.Close()
+}
@@ -3,11 +3,15 @@ module consumer-kafka
 go 1.24.1
 
 require (
+	consumer-core v0.0.0
 	github.com/confluentinc/confluent-kafka-go v1.9.2
-	github.com/go-redis/redis/v8 v8.11.5
 )
 
 require (
 	github.com/cespare/xxhash/v2 v2.1.2 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/go-redis/redis/v8 v8.11.5 // indirect
 )
+
+// Módulo compartido con el consumidor de RabbitMQ
+replace consumer-core => ../core
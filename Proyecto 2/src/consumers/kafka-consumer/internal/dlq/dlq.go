@@ -0,0 +1,96 @@
+// Package dlq define el formato de la cola de mensajes muertos (DLQ) del
+// consumidor de Kafka: los mensajes que no se pueden decodificar se publican
+// en el topic DLQ tal como llegaron, con headers que describen el error y su
+// origen, para inspeccionarlos y reenviarlos al topic principal (ver
+// cmd/kafka-dlq).
+package dlq
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// DefaultTopic es el topic DLQ del topic weather-tweets.
+const DefaultTopic = "weather-tweets.dlq"
+
+// Headers que se agregan a cada mensaje de la DLQ.
+const (
+	headerPrefix    = "dlq."
+	HeaderError     = headerPrefix + "error"            // error de decodificación
+	HeaderTopic     = headerPrefix + "source.topic"     // topic de origen
+	HeaderPartition = headerPrefix + "source.partition" // partición de origen
+	HeaderOffset    = headerPrefix + "source.offset"    // offset de origen
+	HeaderTimestamp = headerPrefix + "timestamp"        // momento en que se apartó, RFC 3339
+	HeaderRedrives  = headerPrefix + "redrives"         // veces que el mensaje se reenvió al topic de origen
+)
+
+// Wrap construye el mensaje de la DLQ para msg: la misma clave, el mismo
+// cuerpo y los mismos headers, más los que describen cause y el origen.
+func Wrap(msg *kafka.Message, topic string, cause error, now time.Time) *kafka.Message {
+	headers := append([]kafka.Header(nil), msg.Headers...)
+	headers = setHeader(headers, HeaderError, cause.Error())
+	if msg.TopicPartition.Topic != nil {
+		headers = setHeader(headers, HeaderTopic, *msg.TopicPartition.Topic)
+	}
+	headers = setHeader(headers, HeaderPartition, strconv.Itoa(int(msg.TopicPartition.Partition)))
+	headers = setHeader(headers, HeaderOffset, strconv.FormatInt(int64(msg.TopicPartition.Offset), 10))
+	headers = setHeader(headers, HeaderTimestamp, now.UTC().Format(time.RFC3339Nano))
+
+	return &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Key:            msg.Key,
+		Value:          msg.Value,
+		Headers:        headers,
+	}
+}
+
+// Redrive construye el mensaje que devuelve un mensaje de la DLQ a su topic
+// de origen (o a fallback si no lo indica). Quita los headers de la DLQ salvo
+// la cantidad de reenvíos, que aumenta en uno, así un mensaje que vuelve a
+// fallar muestra cuántas veces se intentó.
+func Redrive(msg *kafka.Message, fallback string) *kafka.Message {
+	topic := Header(msg, HeaderTopic)
+	if topic == "" {
+		topic = fallback
+	}
+	redrives, _ := strconv.Atoi(Header(msg, HeaderRedrives))
+
+	var headers []kafka.Header
+	for _, h := range msg.Headers {
+		if !strings.HasPrefix(h.Key, headerPrefix) {
+			headers = append(headers, h)
+		}
+	}
+	headers = setHeader(headers, HeaderRedrives, strconv.Itoa(redrives+1))
+
+	return &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Key:            msg.Key,
+		Value:          msg.Value,
+		Headers:        headers,
+	}
+}
+
+// Header devuelve el valor del header key de msg, o "" si no lo tiene.
+func Header(msg *kafka.Message, key string) string {
+	for _, h := range msg.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// setHeader reemplaza el header key o lo agrega si no existe.
+func setHeader(headers []kafka.Header, key, value string) []kafka.Header {
+	for i := range headers {
+		if headers[i].Key == key {
+			headers[i].Value = []byte(value)
+			return headers
+		}
+	}
+	return append(headers, kafka.Header{Key: key, Value: []byte(value)})
+}
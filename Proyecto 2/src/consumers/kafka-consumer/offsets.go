@@ -0,0 +1,217 @@
+package main
+
+import (
+	"log"
+	"sync"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// trackedMessage es un mensaje de Kafka junto con la época de asignación en la
+// que fue leído. Permite ignorar confirmaciones de mensajes cuya partición fue
+// revocada (y quizás reasignada) mientras un worker los procesaba.
+type trackedMessage struct {
+	*kafka.Message
+	epoch uint64
+}
+
+// pendingOffset es un offset leído que todavía no se puede confirmar.
+type pendingOffset struct {
+	offset kafka.Offset
+	done   bool
+}
+
+// partitionOffsets guarda, en orden de lectura, los offsets en vuelo de una partición.
+type partitionOffsets struct {
+	epoch     uint64
+	pending   []*pendingOffset
+	index     map[kafka.Offset]*pendingOffset
+	lastRead  kafka.Offset
+	committed kafka.Offset // último offset confirmado en Kafka (siguiente a leer)
+}
+
+// watermark devuelve el offset a confirmar: el primer offset aún en proceso o,
+// si no hay ninguno, el siguiente al último leído. Todo lo anterior ya fue escrito.
+func (p *partitionOffsets) watermark() kafka.Offset {
+	if len(p.pending) > 0 {
+		return p.pending[0].offset
+	}
+	if p.lastRead < 0 {
+		return kafka.OffsetInvalid
+	}
+	return p.lastRead + 1
+}
+
+// offsetTracker lleva, por partición, qué offsets ya procesaron los workers y
+// sólo confirma la marca contigua más alta. Así un worker rápido no puede
+// confirmar por encima de mensajes que un worker lento todavía no escribe.
+type offsetTracker struct {
+	topic string
+
+	// startOffsets, si está definido, decide desde qué offset reanudar cada
+	// partición asignada (por ejemplo, el último aplicado en Redis).
+	startOffsets func([]kafka.TopicPartition) ([]kafka.TopicPartition, error)
+
+	mu         sync.Mutex
+	epoch      uint64
+	partitions map[int32]*partitionOffsets
+
+	commitMu sync.Mutex // serializa commits para que la marca nunca retroceda
+}
+
+func newOffsetTracker(topic string) *offsetTracker {
+	return &offsetTracker{
+		topic:      topic,
+		partitions: make(map[int32]*partitionOffsets),
+	}
+}
+
+// assign reinicia el estado de las particiones recién asignadas.
+func (t *offsetTracker) assign(partitions []kafka.TopicPartition) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.epoch++
+	for _, tp := range partitions {
+		t.partitions[tp.Partition] = &partitionOffsets{
+			epoch:     t.epoch,
+			index:     make(map[kafka.Offset]*pendingOffset),
+			lastRead:  kafka.OffsetInvalid,
+			committed: kafka.OffsetInvalid,
+		}
+	}
+}
+
+// revoke olvida las particiones revocadas; los mensajes en vuelo de ellas se ignoran.
+func (t *offsetTracker) revoke(partitions []kafka.TopicPartition) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, tp := range partitions {
+		delete(t.partitions, tp.Partition)
+	}
+}
+
+// track registra un mensaje recién leído y lo etiqueta con la época de su partición.
+func (t *offsetTracker) track(msg *kafka.Message) trackedMessage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.partitions[msg.TopicPartition.Partition]
+	if !ok {
+		// Asignación automática sin callback previo: empezar a rastrear aquí
+		t.epoch++
+		p = &partitionOffsets{
+			epoch:     t.epoch,
+			index:     make(map[kafka.Offset]*pendingOffset),
+			lastRead:  kafka.OffsetInvalid,
+			committed: kafka.OffsetInvalid,
+		}
+		t.partitions[msg.TopicPartition.Partition] = p
+	}
+
+	entry := &pendingOffset{offset: msg.TopicPartition.Offset}
+	p.pending = append(p.pending, entry)
+	p.index[entry.offset] = entry
+	p.lastRead = entry.offset
+
+	return trackedMessage{Message: msg, epoch: p.epoch}
+}
+
+// markDone marca los mensajes como procesados y avanza la marca de cada partición.
+func (t *offsetTracker) markDone(messages []trackedMessage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, msg := range messages {
+		p, ok := t.partitions[msg.TopicPartition.Partition]
+		if !ok || p.epoch != msg.epoch {
+			continue // partición revocada mientras se procesaba
+		}
+		if entry, ok := p.index[msg.TopicPartition.Offset]; ok {
+			entry.done = true
+		}
+	}
+
+	for _, p := range t.partitions {
+		for len(p.pending) > 0 && p.pending[0].done {
+			delete(p.index, p.pending[0].offset)
+			p.pending = p.pending[1:]
+		}
+	}
+}
+
+// commit confirma en Kafka la marca contigua de cada partición que avanzó
+// desde el último commit. Si partitions no es nil sólo considera esas.
+func (t *offsetTracker) commit(consumer *kafka.Consumer, partitions []kafka.TopicPartition) error {
+	t.commitMu.Lock()
+	defer t.commitMu.Unlock()
+
+	t.mu.Lock()
+	var offsets []kafka.TopicPartition
+	for partition, p := range t.partitions {
+		if partitions != nil && !containsPartition(partitions, partition) {
+			continue
+		}
+		if mark := p.watermark(); mark >= 0 && mark > p.committed {
+			offsets = append(offsets, kafka.TopicPartition{Topic: &t.topic, Partition: partition, Offset: mark})
+		}
+	}
+	t.mu.Unlock()
+
+	if len(offsets) == 0 {
+		return nil
+	}
+
+	if _, err := consumer.CommitOffsets(offsets); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	for _, tp := range offsets {
+		if p, ok := t.partitions[tp.Partition]; ok && tp.Offset > p.committed {
+			p.committed = tp.Offset
+		}
+	}
+	t.mu.Unlock()
+
+	log.Printf("Committed offsets: %v", offsets)
+	return nil
+}
+
+// rebalanceCallback mantiene el tracker sincronizado con las asignaciones del
+// grupo. Antes de perder particiones confirma lo ya procesado en ellas.
+func (t *offsetTracker) rebalanceCallback(consumer *kafka.Consumer, event kafka.Event) error {
+	switch e := event.(type) {
+	case kafka.AssignedPartitions:
+		log.Printf("Partitions assigned: %v", e.Partitions)
+		partitions := e.Partitions
+		if t.startOffsets != nil {
+			resumed, err := t.startOffsets(partitions)
+			if err != nil {
+				log.Printf("Failed to resolve start offsets, using committed offsets: %v", err)
+			} else {
+				partitions = resumed
+			}
+		}
+		t.assign(partitions)
+		return consumer.Assign(partitions)
+	case kafka.RevokedPartitions:
+		log.Printf("Partitions revoked: %v", e.Partitions)
+		if !consumer.AssignmentLost() {
+			if err := t.commit(consumer, e.Partitions); err != nil {
+				log.Printf("Failed to commit offsets for revoked partitions: %v", err)
+			}
+		}
+		t.revoke(e.Partitions)
+		return consumer.Unassign()
+	}
+	return nil
+}
+
+func containsPartition(partitions []kafka.TopicPartition, partition int32) bool {
+	for _, tp := range partitions {
+		if tp.Partition == partition {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+func testMessage(topic string, partition int32, offset kafka.Offset) *kafka.Message {
+	return &kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: partition, Offset: offset}}
+}
+
+func TestOffsetTrackerWatermark(t *testing.T) {
+	tests := []struct {
+		name string
+		read []kafka.Offset
+		done []kafka.Offset
+		want kafka.Offset
+	}{
+		{"nothing read", nil, nil, kafka.OffsetInvalid},
+		{"nothing done", []kafka.Offset{10, 11, 12}, nil, 10},
+		{"all done", []kafka.Offset{10, 11, 12}, []kafka.Offset{10, 11, 12}, 13},
+		{"prefix done", []kafka.Offset{10, 11, 12}, []kafka.Offset{10}, 11},
+		{"gap stops the watermark", []kafka.Offset{10, 11, 12}, []kafka.Offset{10, 12}, 11},
+		{"only later done", []kafka.Offset{10, 11, 12}, []kafka.Offset{11, 12}, 10},
+		{"offsets with holes", []kafka.Offset{10, 15, 20}, []kafka.Offset{10, 15}, 20},
+		{"unknown offset ignored", []kafka.Offset{10}, []kafka.Offset{9}, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newOffsetTracker("weather-tweets")
+			tracker.assign([]kafka.TopicPartition{{Partition: 0}})
+
+			tracked := make(map[kafka.Offset]trackedMessage)
+			for _, offset := range tt.read {
+				tracked[offset] = tracker.track(testMessage(tracker.topic, 0, offset))
+			}
+			var done []trackedMessage
+			for _, offset := range tt.done {
+				msg, ok := tracked[offset]
+				if !ok {
+					msg = trackedMessage{Message: testMessage(tracker.topic, 0, offset), epoch: tracker.epoch}
+				}
+				done = append(done, msg)
+			}
+			tracker.markDone(done)
+
+			if got := tracker.partitions[0].watermark(); got != tt.want {
+				t.Errorf("watermark = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOffsetTrackerEpoch(t *testing.T) {
+	tracker := newOffsetTracker("weather-tweets")
+	partitions := []kafka.TopicPartition{{Partition: 0}}
+	tracker.assign(partitions)
+	stale := tracker.track(testMessage(tracker.topic, 0, 10))
+
+	// La partición se revoca y se vuelve a asignar mientras un worker procesa stale
+	tracker.revoke(partitions)
+	tracker.assign(partitions)
+	fresh := tracker.track(testMessage(tracker.topic, 0, 10))
+
+	tracker.markDone([]trackedMessage{stale})
+	if got := tracker.partitions[0].watermark(); got != 10 {
+		t.Errorf("watermark after stale ack = %v, want 10", got)
+	}
+
+	tracker.markDone([]trackedMessage{fresh})
+	if got := tracker.partitions[0].watermark(); got != 11 {
+		t.Errorf("watermark after fresh ack = %v, want 11", got)
+	}
+}
+
+func TestOffsetTrackerRevokedPartition(t *testing.T) {
+	tracker := newOffsetTracker("weather-tweets")
+	tracker.assign([]kafka.TopicPartition{{Partition: 0}, {Partition: 1}})
+	msg := tracker.track(testMessage(tracker.topic, 1, 5))
+	tracker.revoke([]kafka.TopicPartition{{Partition: 1}})
+
+	tracker.markDone([]trackedMessage{msg})
+	if _, ok := tracker.partitions[1]; ok {
+		t.Errorf("revoked partition tracked again after markDone")
+	}
+}
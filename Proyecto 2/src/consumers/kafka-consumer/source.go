@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"consumer-core"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// kafkaSource lee el topic con un consumidor de Kafka. Cada partición es un
+// Lane, así sus mensajes se aplican en orden y el offset guardado en los
+// sinks es exacto; los offsets se confirman con el offsetTracker y los
+// mensajes inválidos se apartan en la DLQ.
+type kafkaSource struct {
+	consumer *kafka.Consumer
+	tracker  *offsetTracker
+	dlq      *dlqWriter
+
+	stop context.CancelCauseFunc // detiene Run; lo fija Run antes de entregar mensajes
+}
+
+func (s *kafkaSource) Name() string { return "Kafka" }
+
+// Run lee mensajes hasta que ctx termina o hasta que un Nack lo detiene; en
+// ese caso devuelve el error del lote que no se pudo escribir.
+func (s *kafkaSource) Run(parent context.Context, deliver func(core.Delivery)) error {
+	ctx, stop := context.WithCancelCause(parent)
+	defer stop(nil)
+	s.stop = stop
+
+	for ctx.Err() == nil {
+		msg, err := s.consumer.ReadMessage(100 * time.Millisecond)
+		if err != nil {
+			if err.(kafka.Error).Code() != kafka.ErrTimedOut {
+				log.Printf("Consumer error: %v", err)
+				core.CountError()
+			}
+			continue
+		}
+
+		deliver(core.Delivery{
+			Body:      msg.Value,
+			Lane:      int(msg.TopicPartition.Partition),
+			Partition: msg.TopicPartition.Partition,
+			Offset:    int64(msg.TopicPartition.Offset),
+			HasOffset: true,
+			Ref:       s.tracker.track(msg),
+		})
+	}
+	log.Printf("Stopping Kafka consumer loop: %v", context.Cause(ctx))
+	if parent.Err() == nil {
+		return context.Cause(ctx)
+	}
+	return nil
+}
+
+// Reject publica el mensaje en la DLQ antes de que se confirme con su lote.
+func (s *kafkaSource) Reject(d core.Delivery, cause error) error {
+	return s.dlq.send(d.Ref.(trackedMessage).Message, cause)
+}
+
+// Nack detiene el consumidor. Kafka no puede volver a entregar mensajes
+// sueltos y los lotes posteriores de la partición no se pueden aplicar antes
+// que éste, así que el lote queda sin confirmar: al reiniciarse, el consumidor
+// lo vuelve a leer desde el offset que guardaron los sinks.
+func (s *kafkaSource) Nack(batch []core.Delivery, cause error) error {
+	first := batch[0]
+	s.stop(fmt.Errorf("batch from partition %d offset %d was not written: %w", first.Partition, first.Offset, cause))
+	return nil
+}
+
+// Ack marca los offsets del lote como procesados; el commit en Kafka sólo
+// avanza hasta la marca contigua del tracker.
+func (s *kafkaSource) Ack(batch []core.Delivery) error {
+	messages := make([]trackedMessage, len(batch))
+	for i, d := range batch {
+		messages[i] = d.Ref.(trackedMessage)
+	}
+	s.tracker.markDone(messages)
+	return s.tracker.commit(s.consumer, nil)
+}
+
+func (s *kafkaSource) Health(context.Context) error {
+	_, err := s.consumer.GetMetadata(nil, true, 5000)
+	return err
+}
+
+// Close hace el commit final de la marca de offsets ya procesados y cierra el
+// consumidor y el productor de la DLQ.
+func (s *kafkaSource) Close() error {
+	if err := s.tracker.commit(s.consumer, nil); err != nil {
+		log.Printf("Final commit failed: %v", err)
+	}
+	s.dlq.close()
+	return s.consumer.Close()
+}
+
+// sinkStartOffsets reanuda cada partición asignada desde el offset que
+// indican los sinks (ver core.ResumeOffsets) o, si no lo saben, desde el
+// offset confirmado en Kafka.
+func sinkStartOffsets(sinks []core.Sink, partitions []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+	ids := make([]int32, len(partitions))
+	for i, tp := range partitions {
+		ids[i] = tp.Partition
+	}
+
+	next, err := core.ResumeOffsets(context.Background(), sinks, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	resumed := make([]kafka.TopicPartition, len(partitions))
+	for i, tp := range partitions {
+		resumed[i] = tp
+		resumed[i].Offset = kafka.OffsetStored
+		if offset, ok := next[tp.Partition]; ok {
+			resumed[i].Offset = kafka.Offset(offset)
+		}
+	}
+	return resumed, nil
+}
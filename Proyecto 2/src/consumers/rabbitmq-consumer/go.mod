@@ -3,11 +3,19 @@ module consumer-rabbitmq
 go 1.24.1
 
 require (
-	github.com/go-redis/redis/v8 v8.11.5
+	consumer-core v0.0.0
 	github.com/rabbitmq/amqp091-go v1.10.0
+	servidor-api-go v0.0.0
 )
 
 require (
-	github.com/cespare/xxhash/v2 v2.1.2 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/go-redis/redis/v8 v8.11.5 // indirect
 )
+
+// Módulo compartido con el consumidor de Kafka
+replace consumer-core => ../core
+
+// Topología de RabbitMQ, declarada en el módulo de los writers
+replace servidor-api-go => ../../go-grpc
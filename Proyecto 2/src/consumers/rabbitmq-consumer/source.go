@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"consumer-core"
+	"servidor-api-go/topology"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// rabbitSource consume la cola con un canal AMQP por Lane. Los delivery tags
+// son por canal, así un Ack múltiple de un lote nunca confirma mensajes de
+// otro Lane que todavía no llegaron a los sinks.
+type rabbitSource struct {
+	conn     *amqp.Connection
+	topology topology.Config
+	queue    string
+	channels []*amqp.Channel
+	tags     []string
+	retries  retryPublisher
+}
+
+// newRabbitSource abre lanes canales sobre conn, cada uno con prefetch
+// mensajes sin confirmar como máximo, y el canal de reintentos.
+func newRabbitSource(conn *amqp.Connection, topo topology.Config, lanes, prefetch int) (*rabbitSource, error) {
+	retries := &retryChannel{conn: conn, topology: topo}
+	if err := retries.open(); err != nil {
+		return nil, err
+	}
+	s := &rabbitSource{conn: conn, topology: topo, queue: topo.Queue, retries: retries}
+
+	for i := 0; i < lanes; i++ {
+		ch, err := conn.Channel()
+		if err != nil {
+			return nil, fmt.Errorf("open channel: %w", err)
+		}
+		// Prefetch: el broker no entrega más de `prefetch` mensajes sin confirmar a este canal
+		if err := ch.Qos(prefetch, 0, false); err != nil {
+			return nil, fmt.Errorf("set QoS: %w", err)
+		}
+		s.channels = append(s.channels, ch)
+		s.tags = append(s.tags, fmt.Sprintf("rabbitmq-consumer-%d-%d", os.Getpid(), i))
+	}
+	return s, nil
+}
+
+func (s *rabbitSource) Name() string { return "RabbitMQ" }
+
+// Run registra un consumidor por canal y entrega sus mensajes hasta que ctx
+// termina o se pierde la conexión. Al terminar ctx cancela los consumidores:
+// el broker deja de entregar y Run vuelve cuando se entregó lo ya recibido.
+func (s *rabbitSource) Run(ctx context.Context, deliver func(core.Delivery)) error {
+	var wg sync.WaitGroup
+	for i, ch := range s.channels {
+		deliveries, err := ch.Consume(
+			s.queue,   // queue
+			s.tags[i], // consumer name
+			false,     // auto-ack
+			false,     // exclusive
+			false,     // no-local
+			false,     // no-wait
+			nil,       // args
+		)
+		if err != nil {
+			return fmt.Errorf("register consumer: %w", err)
+		}
+
+		wg.Add(1)
+		go func(lane int) {
+			defer wg.Done()
+			for d := range deliveries {
+				deliver(core.Delivery{Body: d.Body, Lane: lane, Ref: d})
+			}
+		}(i)
+	}
+	log.Printf("RabbitMQ Consumer registered %d consumers on queue '%s'. Waiting for deliveries...", len(s.channels), s.queue)
+
+	connClosed := s.conn.NotifyClose(make(chan *amqp.Error, 1))
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		log.Printf("Stopping RabbitMQ consumers: %v", context.Cause(ctx))
+		for i, ch := range s.channels {
+			if err := ch.Cancel(s.tags[i], false); err != nil {
+				log.Printf("Failed to cancel consumer %s: %v", s.tags[i], err)
+			}
+		}
+	case err := <-connClosed:
+		// Los mensajes sin Ack serán re-entregados por el broker
+		runErr = fmt.Errorf("connection closed: %v", err)
+	}
+
+	wg.Wait()
+	return runErr
+}
+
+// Reject rechaza sin reencolar un mensaje que no se puede decodificar, para
+// que la cola lo aparte en la DLQ: reintentarlo no lo va a arreglar.
+func (s *rabbitSource) Reject(d core.Delivery, cause error) error {
+	delivery := d.Ref.(amqp.Delivery)
+	log.Printf("Sending delivery tag %d to %s: %v", delivery.DeliveryTag, s.topology.DeadLetterQueue(), cause)
+	return delivery.Nack(false, false)
+}
+
+// retryPublisher publica la copia de un mensaje a reintentar y espera la
+// confirmación del broker antes de que el original se confirme.
+type retryPublisher interface {
+	publish(d amqp.Delivery, cause error) error
+	Close() error
+}
+
+// retryChannel publica los reintentos en el exchange de reintentos por un
+// canal en modo confirmación. Una excepción de canal (por ejemplo un exchange
+// borrado) cierra sólo ese canal; se vuelve a abrir en el siguiente
+// reintento, así un canal caído no manda todos los mensajes fallidos de
+// vuelta a la cola de inmediato, sin esperar RetryDelay.
+type retryChannel struct {
+	conn     *amqp.Connection
+	topology topology.Config
+
+	mu     sync.Mutex
+	ch     *amqp.Channel
+	closed chan *amqp.Error
+}
+
+// open abre el canal si no hay uno abierto. Se llama con mu tomado o antes de
+// compartir el retryChannel.
+func (r *retryChannel) open() error {
+	if r.ch != nil {
+		select {
+		case err := <-r.closed:
+			log.Printf("Retry channel closed, reopening it: %v", err)
+			r.ch = nil
+		default:
+			return nil
+		}
+	}
+	ch, err := r.conn.Channel()
+	if err != nil {
+		return fmt.Errorf("open retry channel: %w", err)
+	}
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return fmt.Errorf("enable publisher confirms: %w", err)
+	}
+	r.ch = ch
+	r.closed = ch.NotifyClose(make(chan *amqp.Error, 1))
+	return nil
+}
+
+func (r *retryChannel) publish(d amqp.Delivery, cause error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.open(); err != nil {
+		return err
+	}
+	confirm, err := r.ch.PublishWithDeferredConfirm(
+		r.topology.RetryExchange(), // exchange
+		r.topology.Queue,           // routing key
+		false,                      // mandatory
+		false,                      // immediate
+		topology.RetryPublishing(d, cause),
+	)
+	if err != nil {
+		return err
+	}
+	if !confirm.Wait() {
+		return errors.New("retry nacked by broker")
+	}
+	return nil
+}
+
+func (r *retryChannel) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ch == nil {
+		return nil
+	}
+	return r.ch.Close()
+}
+
+// Ack confirma el lote con un Ack múltiple por canal, hasta el tag más alto
+// que no fue rechazado.
+func (s *rabbitSource) Ack(batch []core.Delivery) error {
+	last := make(map[int]amqp.Delivery)
+	for _, d := range batch {
+		if d.Rejected {
+			continue
+		}
+		delivery := d.Ref.(amqp.Delivery)
+		if prev, ok := last[d.Lane]; !ok || delivery.DeliveryTag > prev.DeliveryTag {
+			last[d.Lane] = delivery
+		}
+	}
+
+	var errs []error
+	for _, delivery := range last {
+		if err := delivery.Ack(true); err != nil {
+			errs = append(errs, fmt.Errorf("ack up to tag %d: %w", delivery.DeliveryTag, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Nack reintenta, después de RetryDelay, los mensajes del lote que los sinks
+// no aceptaron. Si no se puede publicar el reintento, el mensaje vuelve a la
+// cola de inmediato; pasados MaxRetries reintentos se rechaza sin reencolar
+// para que la cola lo aparte en la DLQ.
+func (s *rabbitSource) Nack(batch []core.Delivery, cause error) error {
+	var errs []error
+	for _, d := range batch {
+		if d.Rejected {
+			continue
+		}
+		if err := s.nack(d.Ref.(amqp.Delivery), cause); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *rabbitSource) nack(delivery amqp.Delivery, cause error) error {
+	retries := topology.Retries(delivery.Headers)
+	if retries >= s.topology.MaxRetries {
+		log.Printf("Sending delivery tag %d to %s after %d retries: %v", delivery.DeliveryTag, s.topology.DeadLetterQueue(), retries, cause)
+		return delivery.Nack(false, false)
+	}
+	if err := s.retries.publish(delivery, cause); err != nil {
+		log.Printf("Failed to retry delivery tag %d, requeueing it: %v", delivery.DeliveryTag, err)
+		return delivery.Nack(false, true)
+	}
+	log.Printf("Retrying delivery tag %d in %v (retry %d of %d)", delivery.DeliveryTag, s.topology.RetryDelay, retries+1, s.topology.MaxRetries)
+	return delivery.Ack(false)
+}
+
+// Health verifica que la conexión siga abierta abriendo y cerrando un canal.
+func (s *rabbitSource) Health(context.Context) error {
+	if s.conn.IsClosed() {
+		return errors.New("connection is closed")
+	}
+	ch, err := s.conn.Channel()
+	if err != nil {
+		return fmt.Errorf("channel check failed: %w", err)
+	}
+	return ch.Close()
+}
+
+// Close cierra los canales y la conexión.
+func (s *rabbitSource) Close() error {
+	for _, ch := range s.channels {
+		ch.Close()
+	}
+	s.retries.Close()
+	return s.conn.Close()
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"consumer-core"
+	"servidor-api-go/topology"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// testAcknowledger registra lo que se confirma en cada canal.
+type testAcknowledger struct {
+	calls []string
+}
+
+func (a *testAcknowledger) Ack(tag uint64, multiple bool) error {
+	a.calls = append(a.calls, fmt.Sprintf("ack %d multiple=%v", tag, multiple))
+	return nil
+}
+
+func (a *testAcknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	a.calls = append(a.calls, fmt.Sprintf("nack %d requeue=%v", tag, requeue))
+	return nil
+}
+
+func (a *testAcknowledger) Reject(tag uint64, requeue bool) error {
+	a.calls = append(a.calls, fmt.Sprintf("reject %d requeue=%v", tag, requeue))
+	return nil
+}
+
+// testRetries registra los reintentos publicados o falla con err.
+type testRetries struct {
+	err       error
+	published []uint64
+}
+
+func (r *testRetries) publish(d amqp.Delivery, cause error) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.published = append(r.published, d.DeliveryTag)
+	return nil
+}
+
+func (r *testRetries) Close() error { return nil }
+
+func TestRabbitSourceConfirmations(t *testing.T) {
+	type message struct {
+		lane     int
+		tag      uint64
+		retries  int32 // header x-retries
+		rejected bool  // apartado por el Pipeline con Reject
+	}
+	tests := []struct {
+		name     string
+		op       string // reject, ack o nack
+		messages []message
+		retryErr error
+
+		wantCalls     map[int][]string // llamadas por lane
+		wantPublished []uint64
+	}{
+		{
+			name:      "reject sends the message to the DLQ",
+			op:        "reject",
+			messages:  []message{{lane: 0, tag: 4}},
+			wantCalls: map[int][]string{0: {"nack 4 requeue=false"}},
+		},
+		{
+			name: "ack confirms up to the highest tag of each lane",
+			op:   "ack",
+			messages: []message{
+				{lane: 0, tag: 1}, {lane: 1, tag: 1}, {lane: 0, tag: 3}, {lane: 0, tag: 2}, {lane: 1, tag: 2},
+			},
+			wantCalls: map[int][]string{0: {"ack 3 multiple=true"}, 1: {"ack 2 multiple=true"}},
+		},
+		{
+			name:      "ack skips rejected messages",
+			op:        "ack",
+			messages:  []message{{lane: 0, tag: 1}, {lane: 0, tag: 2, rejected: true}},
+			wantCalls: map[int][]string{0: {"ack 1 multiple=true"}},
+		},
+		{
+			name:          "nack retries and acks the original",
+			op:            "nack",
+			messages:      []message{{lane: 0, tag: 1}, {lane: 1, tag: 7, retries: 2}},
+			wantCalls:     map[int][]string{0: {"ack 1 multiple=false"}, 1: {"ack 7 multiple=false"}},
+			wantPublished: []uint64{1, 7},
+		},
+		{
+			name:      "nack after MaxRetries sends the message to the DLQ",
+			op:        "nack",
+			messages:  []message{{lane: 0, tag: 1, retries: 3}},
+			wantCalls: map[int][]string{0: {"nack 1 requeue=false"}},
+		},
+		{
+			name:      "nack requeues when the retry cannot be published",
+			op:        "nack",
+			messages:  []message{{lane: 0, tag: 1}},
+			retryErr:  errors.New("channel closed"),
+			wantCalls: map[int][]string{0: {"nack 1 requeue=true"}},
+		},
+		{
+			name:      "nack skips rejected messages",
+			op:        "nack",
+			messages:  []message{{lane: 0, tag: 1, rejected: true}},
+			wantCalls: map[int][]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retries := &testRetries{err: tt.retryErr}
+			s := &rabbitSource{topology: topology.Config{Queue: topology.Queue, MaxRetries: 3}, queue: topology.Queue, retries: retries}
+
+			acks := make(map[int]*testAcknowledger)
+			var batch []core.Delivery
+			for _, m := range tt.messages {
+				if acks[m.lane] == nil {
+					acks[m.lane] = &testAcknowledger{}
+				}
+				d := amqp.Delivery{Acknowledger: acks[m.lane], DeliveryTag: m.tag, Headers: amqp.Table{}}
+				if m.retries > 0 {
+					d.Headers[topology.HeaderRetries] = m.retries
+				}
+				batch = append(batch, core.Delivery{Lane: m.lane, Ref: d, Rejected: m.rejected})
+			}
+
+			var err error
+			switch tt.op {
+			case "reject":
+				err = s.Reject(batch[0], errors.New("invalid JSON"))
+			case "ack":
+				err = s.Ack(batch)
+			case "nack":
+				err = s.Nack(batch, errors.New("sink unavailable"))
+			}
+			if err != nil {
+				t.Fatalf("%s: %v", tt.op, err)
+			}
+
+			calls := make(map[int][]string)
+			for lane, a := range acks {
+				if len(a.calls) > 0 {
+					calls[lane] = a.calls
+				}
+			}
+			if !reflect.DeepEqual(calls, tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+			if !reflect.DeepEqual(retries.published, tt.wantPublished) {
+				t.Errorf("retried tags = %v, want %v", retries.published, tt.wantPublished)
+			}
+		})
+	}
+}
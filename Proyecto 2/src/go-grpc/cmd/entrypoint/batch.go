@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+
+	"servidor-api-go/internal/proto"
+)
+
+const (
+	// maxBatchBodyBytes limita el tamaño del cuerpo aceptado en /input/batch.
+	maxBatchBodyBytes = 8 << 20
+	// maxBatchItems limita la cantidad de reportes por petición.
+	maxBatchItems = 1000
+)
+
+// batchItemResult es el resultado de un reporte dentro de un lote. Status
+// sigue a /input: "accepted", "partial" (quorum alcanzado, faltan copias),
+// "spooled" (pendiente de reenvío) o "failed" (no se pudo publicar), además
+// de "rejected" para los reportes que no pasaron la validación.
+type batchItemResult struct {
+	Index    int             `json:"index"`
+	ID       string          `json:"id,omitempty"`
+	Status   string          `json:"status"`
+	Error    string          `json:"error,omitempty"`
+	Fields   []fieldError    `json:"fields,omitempty"` // detalle si el reporte no es válido
+	Spooled  bool            `json:"spooled,omitempty"`
+	Backends []backendResult `json:"backends,omitempty"`
+}
+
+// batchResponse resume el procesamiento de un lote.
+type batchResponse struct {
+	Accepted int               `json:"accepted"`
+	Rejected int               `json:"rejected"`
+	Failed   int               `json:"failed"`
+	Results  []batchItemResult `json:"results"`
+}
+
+// handleBatchInput acepta un arreglo JSON o un flujo NDJSON
+// (application/x-ndjson) de reportes, los valida uno por uno, publica los
+// válidos y responde con el resultado de cada elemento.
+func handleBatchInput(f *fanout) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("--> Received HTTP request on %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body := http.MaxBytesReader(w, r.Body, maxBatchBodyBytes)
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+		var items []json.RawMessage
+		var err error
+		if mediaType == "application/x-ndjson" {
+			items, err = splitNDJSON(body)
+		} else {
+			items, err = splitJSONArray(body)
+		}
+		if err != nil {
+			log.Printf("Invalid batch body: %v", err)
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if len(items) == 0 {
+			http.Error(w, "Empty batch", http.StatusBadRequest)
+			return
+		}
+		if len(items) > maxBatchItems {
+			http.Error(w, fmt.Sprintf("Batch exceeds %d items", maxBatchItems), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		log.Printf("Processing batch of %d tweets", len(items))
+
+		results := make([]batchItemResult, len(items))
+		tweets := make([]*proto.WeatherRequest, len(items))
+		for i, raw := range items {
+			results[i] = batchItemResult{Index: i}
+			tweet, errs := decodeReport(raw)
+			if errs != nil {
+				results[i].Status = "rejected"
+				results[i].Error = fmt.Sprintf("invalid item: %v", errs)
+				results[i].Fields = errs.Fields
+				continue
+			}
+			if err := validateRequest(tweet); err != nil {
+				results[i].Status = "rejected"
+				results[i].Error = err.Error()
+				results[i].Fields = err.(*validationError).Fields
+				continue
+			}
+			stampRequest(tweet, "http")
+			results[i].ID = tweet.GetId()
+			tweets[i] = tweet
+		}
+
+		// Publicar los reportes válidos con las RPC de lote de los writers
+		var valid []*proto.WeatherRequest
+		var positions []int
+		for i, tweet := range tweets {
+			if tweet != nil {
+				valid = append(valid, tweet)
+				positions = append(positions, i)
+			}
+		}
+		// Un fallo pasajero decide el código aunque haya reportes inválidos: el
+		// cliente tiene que poder reintentar el lote
+		transient, spoolFull := false, false
+		for j, result := range f.ingestBatch(r.Context(), valid, nil) {
+			i := positions[j]
+			results[i].Status = result.status
+			results[i].Spooled = result.spooled
+			results[i].Backends = result.outcome.Backends
+			if err := result.err(); err != nil {
+				results[i].Error = fmt.Sprintf("publish failed: %v", err)
+				transient = transient || !result.rejected()
+				spoolFull = spoolFull || errors.Is(result.spoolErr, errSpoolFull)
+			}
+		}
+
+		resp := batchResponse{Results: results}
+		partial := false
+		for _, res := range results {
+			switch res.Status {
+			case "rejected":
+				resp.Rejected++
+			case ingestFailed:
+				resp.Failed++
+			default:
+				resp.Accepted++
+			}
+			partial = partial || res.Status == ingestPartial
+		}
+
+		status := http.StatusAccepted
+		switch {
+		case resp.Accepted == 0 && spoolFull:
+			status = http.StatusServiceUnavailable
+		case resp.Accepted == 0 && transient:
+			status = http.StatusBadGateway
+		case resp.Accepted == 0:
+			status = http.StatusUnprocessableEntity
+		case resp.Rejected > 0 || resp.Failed > 0 || partial:
+			status = http.StatusMultiStatus
+		}
+
+		log.Printf("Batch processed: %d accepted, %d rejected, %d failed", resp.Accepted, resp.Rejected, resp.Failed)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// splitJSONArray separa un arreglo JSON en sus elementos sin decodificarlos.
+func splitJSONArray(r io.Reader) ([]json.RawMessage, error) {
+	var items []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// splitNDJSON separa un flujo NDJSON en sus líneas no vacías.
+func splitNDJSON(r io.Reader) ([]json.RawMessage, error) {
+	var items []json.RawMessage
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxBodyBytes)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		items = append(items, json.RawMessage(bytes.Clone(line)))
+		if len(items) > maxBatchItems {
+			break
+		}
+	}
+	return items, scanner.Err()
+}
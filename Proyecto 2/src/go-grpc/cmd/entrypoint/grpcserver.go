@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	protobuf "google.golang.org/protobuf/proto"
+	"servidor-api-go/internal/proto"
+)
+
+// grpcServer es la API de ingesta gRPC del entrypoint. Comparte con /input la
+// validación, el enrutamiento, el fan-out, el spool y la idempotencia.
+type grpcServer struct {
+	proto.UnimplementedPublisherServiceServer
+	f *fanout
+}
+
+func startGRPCServer(f *fanout, idempotency idempotencyConfig) {
+	lis, err := net.Listen("tcp", ":50050")
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+
+	s := grpc.NewServer(grpc.UnaryInterceptor(idempotencyInterceptor(idempotency)))
+	server := &grpcServer{f: f}
+	proto.RegisterPublisherServiceServer(s, server)
+	proto.RegisterWeatherServiceServer(s, &legacyGRPCServer{server: server})
+
+	log.Printf("gRPC server listening at %v", lis.Addr())
+	if err := s.Serve(lis); err != nil {
+		log.Fatalf("failed to serve: %v", err)
+	}
+}
+
+// Describe informa que detrás del entrypoint están sus writers.
+func (s *grpcServer) Describe(ctx context.Context, _ *proto.DescribeRequest) (*proto.DescribeResponse, error) {
+	var writers []string
+	for _, b := range s.f.backends {
+		writers = append(writers, b.label())
+	}
+	return &proto.DescribeResponse{Backend: "entrypoint", Destination: strings.Join(writers, ",")}, nil
+}
+
+func (s *grpcServer) Publish(ctx context.Context, tweet *proto.WeatherRequest) (*proto.WeatherResponse, error) {
+	return s.publish(ctx, tweet, nil)
+}
+
+// publish valida, sella y publica un reporte según plan (o la política activa).
+func (s *grpcServer) publish(ctx context.Context, tweet *proto.WeatherRequest, plan *routePlan) (*proto.WeatherResponse, error) {
+	if err := validateRequest(tweet); err != nil {
+		return nil, err.(*validationError).grpcStatus()
+	}
+	stampRequest(tweet, "grpc")
+	log.Printf("Processing gRPC tweet: %v", tweet)
+
+	result := s.f.ingest(ctx, tweet, plan)
+	resp := &proto.WeatherResponse{Id: tweet.GetId(), Success: true}
+	switch result.status {
+	case ingestAccepted:
+		resp.Message = "Report accepted"
+	case ingestPartial:
+		resp.Message = "Report accepted, some writers did not receive it"
+		resp.Code = "PARTIAL"
+	case ingestSpooled:
+		resp.Message = "Report spooled for later delivery"
+		resp.Code = "SPOOLED"
+	default:
+		// Sólo los fallos pasajeros son Unavailable: los clientes gRPC los reintentan
+		if result.rejected() {
+			return nil, status.Errorf(codes.FailedPrecondition, "report rejected by writers: %v", result.err())
+		}
+		if errors.Is(result.spoolErr, errSpoolFull) {
+			return nil, status.Errorf(codes.ResourceExhausted, "writers unavailable and spool is full: %v", result.err())
+		}
+		return nil, status.Errorf(codes.Unavailable, "failed to publish report: %v", result.err())
+	}
+	return resp, nil
+}
+
+func (s *grpcServer) PublishBatch(ctx context.Context, batch *proto.WeatherBatchRequest) (*proto.WeatherBatchResponse, error) {
+	if len(batch.GetRequests()) > maxBatchItems {
+		return nil, status.Errorf(codes.InvalidArgument, "batch exceeds %d items", maxBatchItems)
+	}
+	log.Printf("Processing gRPC batch of %d tweets", len(batch.GetRequests()))
+	return s.publishBatch(ctx, batch.GetRequests(), nil), nil
+}
+
+// StreamPublish recibe un flujo de reportes y los publica en bloques de
+// writerBatchSize; al cerrar el flujo responde con el resultado de cada uno.
+func (s *grpcServer) StreamPublish(stream proto.PublisherService_StreamPublishServer) error {
+	resp := &proto.WeatherBatchResponse{}
+	var pending []*proto.WeatherRequest
+
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		offset := int32(len(resp.Results))
+		chunk := s.publishBatch(stream.Context(), pending, nil)
+		for _, result := range chunk.Results {
+			result.Index += offset
+		}
+		resp.Results = append(resp.Results, chunk.Results...)
+		resp.Published += chunk.Published
+		resp.Failed += chunk.Failed
+		pending = nil
+	}
+
+	for {
+		tweet, err := stream.Recv()
+		if err == io.EOF {
+			flush()
+			log.Printf("StreamPublish finished: %d published, %d failed", resp.Published, resp.Failed)
+			return stream.SendAndClose(resp)
+		}
+		if err != nil {
+			log.Printf("StreamPublish receive error: %v", err)
+			return err
+		}
+		pending = append(pending, tweet)
+		if len(pending) >= writerBatchSize {
+			flush()
+		}
+	}
+}
+
+// publishBatch valida y sella cada reporte, publica los válidos con las RPC de
+// lote de los writers y devuelve un resultado por reporte.
+func (s *grpcServer) publishBatch(ctx context.Context, tweets []*proto.WeatherRequest, plan *routePlan) *proto.WeatherBatchResponse {
+	resp := &proto.WeatherBatchResponse{Results: make([]*proto.WeatherResult, len(tweets))}
+
+	var valid []*proto.WeatherRequest
+	var positions []int
+	for i, tweet := range tweets {
+		resp.Results[i] = &proto.WeatherResult{Index: int32(i)}
+		if err := validateRequest(tweet); err != nil {
+			resp.Results[i].Message = err.Error()
+			resp.Results[i].Code = "INVALID_ARGUMENT"
+			continue
+		}
+		stampRequest(tweet, "grpc")
+		resp.Results[i].Id = tweet.GetId()
+		valid = append(valid, tweet)
+		positions = append(positions, i)
+	}
+
+	for j, result := range s.f.ingestBatch(ctx, valid, plan) {
+		item := resp.Results[positions[j]]
+		switch result.status {
+		case ingestAccepted:
+			item.Success = true
+			item.Message = "Report accepted"
+		case ingestPartial:
+			item.Success = true
+			item.Message = "Report accepted, some writers did not receive it"
+			item.Code = "PARTIAL"
+		case ingestSpooled:
+			item.Success = true
+			item.Message = "Report spooled for later delivery"
+			item.Code = "SPOOLED"
+		default:
+			if result.rejected() {
+				item.Message = fmt.Sprintf("rejected by writers: %v", result.err())
+				item.Code = "REJECTED"
+				break
+			}
+			item.Message = fmt.Sprintf("publish failed: %v", result.err())
+			item.Code = "PUBLISH_FAILED"
+		}
+	}
+
+	for _, result := range resp.Results {
+		if result.Success {
+			resp.Published++
+		} else {
+			resp.Failed++
+		}
+	}
+	log.Printf("gRPC batch processed: %d published, %d failed", resp.Published, resp.Failed)
+	return resp
+}
+
+// legacyGRPCServer mantiene WeatherService para los clientes que eligen el
+// broker: cada método publica sólo en el writer de ese backend.
+type legacyGRPCServer struct {
+	proto.UnimplementedWeatherServiceServer
+	server *grpcServer
+}
+
+func (s *legacyGRPCServer) PublishToKafka(ctx context.Context, tweet *proto.WeatherRequest) (*proto.WeatherResponse, error) {
+	plan, err := s.planFor("kafka")
+	if err != nil {
+		return nil, err
+	}
+	return s.server.publish(ctx, tweet, plan)
+}
+
+func (s *legacyGRPCServer) PublishToRabbitMQ(ctx context.Context, tweet *proto.WeatherRequest) (*proto.WeatherResponse, error) {
+	plan, err := s.planFor("rabbitmq")
+	if err != nil {
+		return nil, err
+	}
+	return s.server.publish(ctx, tweet, plan)
+}
+
+func (s *legacyGRPCServer) PublishBatchToKafka(ctx context.Context, batch *proto.WeatherBatchRequest) (*proto.WeatherBatchResponse, error) {
+	return s.publishBatch(ctx, batch, "kafka")
+}
+
+func (s *legacyGRPCServer) PublishBatchToRabbitMQ(ctx context.Context, batch *proto.WeatherBatchRequest) (*proto.WeatherBatchResponse, error) {
+	return s.publishBatch(ctx, batch, "rabbitmq")
+}
+
+func (s *legacyGRPCServer) publishBatch(ctx context.Context, batch *proto.WeatherBatchRequest, backend string) (*proto.WeatherBatchResponse, error) {
+	if len(batch.GetRequests()) > maxBatchItems {
+		return nil, status.Errorf(codes.InvalidArgument, "batch exceeds %d items", maxBatchItems)
+	}
+	plan, err := s.planFor(backend)
+	if err != nil {
+		return nil, err
+	}
+	return s.server.publishBatch(ctx, batch.GetRequests(), plan), nil
+}
+
+// planFor arma un plan que publica sólo en el writer de backend.
+func (s *legacyGRPCServer) planFor(backend string) (*routePlan, error) {
+	if s.server.f.lookup(backend) == nil {
+		return nil, status.Errorf(codes.Unavailable, "no %s writer available", backend)
+	}
+	return &routePlan{targets: []string{backend}}, nil
+}
+
+// idempotencyKeyMetadata es el equivalente gRPC del header Idempotency-Key.
+const idempotencyKeyMetadata = "idempotency-key"
+
+// idempotentMethods crea, por método unario de ingesta, la respuesta vacía en
+// la que se decodifica una respuesta guardada.
+var idempotentMethods = map[string]func() protobuf.Message{
+	proto.PublisherService_Publish_FullMethodName:              func() protobuf.Message { return &proto.WeatherResponse{} },
+	proto.PublisherService_PublishBatch_FullMethodName:         func() protobuf.Message { return &proto.WeatherBatchResponse{} },
+	proto.WeatherService_PublishToKafka_FullMethodName:         func() protobuf.Message { return &proto.WeatherResponse{} },
+	proto.WeatherService_PublishToRabbitMQ_FullMethodName:      func() protobuf.Message { return &proto.WeatherResponse{} },
+	proto.WeatherService_PublishBatchToKafka_FullMethodName:    func() protobuf.Message { return &proto.WeatherBatchResponse{} },
+	proto.WeatherService_PublishBatchToRabbitMQ_FullMethodName: func() protobuf.Message { return &proto.WeatherBatchResponse{} },
+}
+
+// idempotencyInterceptor aplica a las llamadas gRPC la misma deduplicación que
+// withIdempotency aplica a HTTP, usando el metadato idempotency-key. Sólo se
+// guardan las respuestas exitosas; un error libera la clave.
+func idempotencyInterceptor(cfg idempotencyConfig) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		newResponse, ok := idempotentMethods[info.FullMethod]
+		keys := metadata.ValueFromIncomingContext(ctx, idempotencyKeyMetadata)
+		if !ok || len(keys) == 0 || keys[0] == "" {
+			return handler(ctx, req)
+		}
+		key := info.FullMethod + "|" + keys[0]
+
+		// Marshal determinístico: el mismo pedido produce siempre los mismos bytes
+		reqBytes, err := protobuf.MarshalOptions{Deterministic: true}.Marshal(req.(protobuf.Message))
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "marshal request: %v", err)
+		}
+		fingerprint := hashBytes(reqBytes)
+
+		cached, reserved, err := cfg.store.Reserve(ctx, key, fingerprint, cfg.ttl)
+		if errors.Is(err, errIdempotencyKeyReused) {
+			return nil, status.Error(codes.InvalidArgument, "idempotency key was already used with a different request")
+		}
+		if err != nil {
+			log.Printf("Idempotency store error, processing without deduplication: %v", err)
+			return handler(ctx, req)
+		}
+		if !reserved {
+			if cached == nil {
+				return nil, status.Error(codes.Aborted, "a request with this idempotency key is already in progress")
+			}
+			resp := newResponse()
+			if err := protobuf.Unmarshal(cached.Body, resp); err != nil {
+				return nil, status.Errorf(codes.Internal, "stored response for idempotency key is invalid: %v", err)
+			}
+			log.Printf("Replaying stored response for idempotency key %q", key)
+			grpc.SetHeader(ctx, metadata.Pairs("idempotent-replayed", "true"))
+			return resp, nil
+		}
+
+		resp, err := handler(ctx, req)
+
+		// La llamada original pudo cancelarse; el almacén no debe depender de su contexto
+		storeCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if err == nil {
+			body, marshalErr := protobuf.Marshal(resp.(protobuf.Message))
+			if marshalErr == nil {
+				marshalErr = cfg.store.Complete(storeCtx, key, &cachedResponse{Status: 200, ContentType: "application/grpc+proto", Body: body, Fingerprint: fingerprint}, cfg.ttl)
+			}
+			if marshalErr != nil {
+				log.Printf("Failed to store response for idempotency key %q: %v", key, marshalErr)
+			}
+			return resp, nil
+		}
+		if releaseErr := cfg.store.Release(storeCtx, key); releaseErr != nil {
+			log.Printf("Failed to release idempotency key %q: %v", key, releaseErr)
+		}
+		return resp, err
+	}
+}
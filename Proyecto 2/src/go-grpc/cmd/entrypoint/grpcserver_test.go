@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"servidor-api-go/internal/proto"
+)
+
+func TestPublishStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		writer    *fakeWriter
+		spoolFull bool
+
+		wantCode     codes.Code
+		wantHTTP     int
+		wantItemCode string // código del resultado en PublishBatch
+	}{
+		{
+			name:     "published",
+			writer:   &fakeWriter{accept: -1},
+			wantCode: codes.OK,
+			wantHTTP: http.StatusAccepted,
+		},
+		{
+			name:         "rejected by the writer",
+			writer:       &fakeWriter{failCode: "MARSHAL_FAILED"},
+			wantCode:     codes.FailedPrecondition,
+			wantHTTP:     http.StatusUnprocessableEntity,
+			wantItemCode: "REJECTED",
+		},
+		{
+			name:         "writer down",
+			writer:       &fakeWriter{},
+			wantCode:     codes.Unavailable,
+			wantHTTP:     http.StatusBadGateway,
+			wantItemCode: "PUBLISH_FAILED",
+		},
+		{
+			name:         "writer down and spool full",
+			writer:       &fakeWriter{},
+			spoolFull:    true,
+			wantCode:     codes.ResourceExhausted,
+			wantHTTP:     http.StatusServiceUnavailable,
+			wantItemCode: "PUBLISH_FAILED",
+		},
+	}
+	report := func() *proto.WeatherRequest {
+		return &proto.WeatherRequest{Description: "Sol", Country: "GT", WeatherType: proto.Weather_WEATHER_SOLEADO}
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s *spool
+			if tt.spoolFull {
+				s = openTestSpool(t, t.TempDir(), 1)
+			}
+			f := newTestFanout(t, s, newTestBackend("kafka", tt.writer))
+			server := &grpcServer{f: f}
+
+			_, err := server.Publish(context.Background(), report())
+			if got := status.Code(err); got != tt.wantCode {
+				t.Errorf("Publish() code = %v (%v), want %v", got, err, tt.wantCode)
+			}
+
+			batch := server.publishBatch(context.Background(), []*proto.WeatherRequest{report()}, nil)
+			if got := batch.Results[0].GetCode(); got != tt.wantItemCode {
+				t.Errorf("PublishBatch() item code = %q, want %q", got, tt.wantItemCode)
+			}
+
+			body := `{"description":"Sol","country":"GT","weather":"soleado"}`
+			w := httptest.NewRecorder()
+			handleInput(f)(w, httptest.NewRequest(http.MethodPost, "/input", strings.NewReader(body)))
+			if w.Code != tt.wantHTTP {
+				t.Errorf("/input status = %d, want %d (%s)", w.Code, tt.wantHTTP, w.Body)
+			}
+		})
+	}
+}
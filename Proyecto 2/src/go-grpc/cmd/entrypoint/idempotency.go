@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// errIdempotencyKeyReused indica que la clave ya se usó con otro contenido.
+var errIdempotencyKeyReused = errors.New("idempotency key was already used with a different request")
+
+// cachedResponse es la respuesta original que se devuelve ante un reintento.
+type cachedResponse struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+	// Fingerprint es el hash del contenido de la petición original.
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// idempotencyStore recuerda las claves de idempotencia vistas recientemente.
+type idempotencyStore interface {
+	// Reserve marca key como en curso para la petición con hash fingerprint.
+	// Si la clave ya existe devuelve reserved=false y la respuesta guardada, o
+	// nil si la petición original todavía no termina; si la petición original
+	// tenía otro contenido devuelve errIdempotencyKeyReused.
+	Reserve(ctx context.Context, key, fingerprint string, ttl time.Duration) (cached *cachedResponse, reserved bool, err error)
+	// Complete guarda la respuesta final de key; resp.Fingerprint es el de la reserva.
+	Complete(ctx context.Context, key string, resp *cachedResponse, ttl time.Duration) error
+	// Release libera key para que un reintento vuelva a procesarse.
+	Release(ctx context.Context, key string) error
+}
+
+// idempotencyConfig controla el middleware de idempotencia de /input.
+type idempotencyConfig struct {
+	store idempotencyStore
+	ttl   time.Duration
+	// hashBody deriva la clave del contenido cuando el cliente no envía el header.
+	hashBody bool
+}
+
+// newIdempotencyConfigFromEnv arma la configuración a partir de variables de entorno:
+// IDEMPOTENCY_STORE (memory|redis), IDEMPOTENCY_REDIS_ADDR, IDEMPOTENCY_TTL,
+// IDEMPOTENCY_CACHE_SIZE e IDEMPOTENCY_HASH_BODY.
+func newIdempotencyConfigFromEnv() idempotencyConfig {
+	ttl, err := time.ParseDuration(getEnv("IDEMPOTENCY_TTL", "10m"))
+	if err != nil {
+		log.Fatalf("Invalid IDEMPOTENCY_TTL: %v", err)
+	}
+	hashBody, err := strconv.ParseBool(getEnv("IDEMPOTENCY_HASH_BODY", "false"))
+	if err != nil {
+		log.Fatalf("Invalid IDEMPOTENCY_HASH_BODY: %v", err)
+	}
+
+	var store idempotencyStore
+	switch kind := getEnv("IDEMPOTENCY_STORE", "memory"); kind {
+	case "memory":
+		size, err := strconv.Atoi(getEnv("IDEMPOTENCY_CACHE_SIZE", "10000"))
+		if err != nil || size < 1 {
+			log.Fatalf("Invalid IDEMPOTENCY_CACHE_SIZE: %q", getEnv("IDEMPOTENCY_CACHE_SIZE", ""))
+		}
+		store = newMemoryIdempotencyStore(size)
+	case "redis":
+		store = newRedisIdempotencyStore(getEnv("IDEMPOTENCY_REDIS_ADDR", "redis-service:6379"))
+	default:
+		log.Fatalf("Unknown IDEMPOTENCY_STORE %q", kind)
+	}
+	log.Printf("Idempotency store: %s (ttl %v, hash body %v)", getEnv("IDEMPOTENCY_STORE", "memory"), ttl, hashBody)
+
+	return idempotencyConfig{store: store, ttl: ttl, hashBody: hashBody}
+}
+
+// withIdempotency envuelve un handler de ingesta: si la clave ya se procesó
+// devuelve la respuesta original sin volver a publicar. Sólo las respuestas
+// 2xx se recuerdan; los errores liberan la clave para permitir reintentos.
+// Reusar una clave con otro cuerpo se rechaza con 422.
+func withIdempotency(cfg idempotencyConfig, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next(w, r)
+			return
+		}
+
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" && !cfg.hashBody {
+			next(w, r)
+			return
+		}
+
+		// Límite del endpoint más permisivo; cada handler aplica el suyo
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBatchBodyBytes))
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := hashBytes(body)
+		if key == "" {
+			key = "sha256:" + fingerprint
+		}
+		key = r.URL.Path + "|" + key
+
+		cached, reserved, err := cfg.store.Reserve(r.Context(), key, fingerprint, cfg.ttl)
+		if errors.Is(err, errIdempotencyKeyReused) {
+			http.Error(w, "Idempotency key was already used with a different request body", http.StatusUnprocessableEntity)
+			return
+		}
+		if err != nil {
+			// Sin almacén disponible se procesa normalmente
+			log.Printf("Idempotency store error, processing without deduplication: %v", err)
+			next(w, r)
+			return
+		}
+		if !reserved {
+			if cached == nil {
+				http.Error(w, "A request with this idempotency key is already in progress", http.StatusConflict)
+				return
+			}
+			log.Printf("Replaying stored response for idempotency key %q", key)
+			w.Header().Set("Content-Type", cached.ContentType)
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(cached.Status)
+			w.Write(cached.Body)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+
+		// La petición original pudo cancelarse; el almacén no debe depender de su contexto
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if rec.status >= 200 && rec.status < 300 {
+			resp := &cachedResponse{
+				Status:      rec.status,
+				ContentType: rec.Header().Get("Content-Type"),
+				Body:        rec.body.Bytes(),
+				Fingerprint: fingerprint,
+			}
+			if err := cfg.store.Complete(ctx, key, resp, cfg.ttl); err != nil {
+				log.Printf("Failed to store response for idempotency key %q: %v", key, err)
+			}
+			return
+		}
+		if err := cfg.store.Release(ctx, key); err != nil {
+			log.Printf("Failed to release idempotency key %q: %v", key, err)
+		}
+	}
+}
+
+// hashBytes devuelve el SHA-256 de b en hexadecimal.
+func hashBytes(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// responseRecorder copia el status y el cuerpo de la respuesta mientras se escriben.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// memoryIdempotencyStore es un LRU en memoria con expiración por entrada. Las
+// claves en curso no se desalojan: hacerlo dejaría pasar un duplicado
+// concurrente. Mientras todas lo estén, el LRU puede pasar de su capacidad.
+type memoryIdempotencyStore struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // frente = usada más recientemente
+	entries  map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key         string
+	fingerprint string
+	resp        *cachedResponse // nil mientras la petición está en curso
+	expires     time.Time
+}
+
+func newMemoryIdempotencyStore(capacity int) *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+func (s *memoryIdempotencyStore) Reserve(_ context.Context, key, fingerprint string, ttl time.Duration) (*cachedResponse, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.entries[key]; ok {
+		entry := el.Value.(*memoryEntry)
+		if time.Now().Before(entry.expires) {
+			s.order.MoveToFront(el)
+			if entry.fingerprint != fingerprint {
+				return nil, false, errIdempotencyKeyReused
+			}
+			return entry.resp, false, nil
+		}
+		s.remove(el)
+	}
+
+	s.entries[key] = s.order.PushFront(&memoryEntry{key: key, fingerprint: fingerprint, expires: time.Now().Add(ttl)})
+	s.evict()
+	return nil, true, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(_ context.Context, key string, resp *cachedResponse, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.entries[key]
+	if !ok {
+		el = s.order.PushFront(&memoryEntry{key: key, fingerprint: resp.Fingerprint})
+		s.entries[key] = el
+	}
+	entry := el.Value.(*memoryEntry)
+	entry.resp = resp
+	entry.expires = time.Now().Add(ttl)
+	s.order.MoveToFront(el)
+	s.evict()
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.entries[key]; ok {
+		s.remove(el)
+	}
+	return nil
+}
+
+// evict desaloja las entradas terminadas usadas hace más tiempo hasta volver
+// a la capacidad.
+func (s *memoryIdempotencyStore) evict() {
+	for el := s.order.Back(); el != nil && s.order.Len() > s.capacity; {
+		prev := el.Prev()
+		if el.Value.(*memoryEntry).resp != nil {
+			s.remove(el)
+		}
+		el = prev
+	}
+}
+
+func (s *memoryIdempotencyStore) remove(el *list.Element) {
+	s.order.Remove(el)
+	delete(s.entries, el.Value.(*memoryEntry).key)
+}
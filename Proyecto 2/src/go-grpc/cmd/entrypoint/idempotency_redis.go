@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// redisKeyPrefix agrupa las claves de idempotencia en Redis.
+const redisKeyPrefix = "idempotency:"
+
+// redisIdempotencyStore comparte las claves de idempotencia entre réplicas del
+// entrypoint. Una clave en curso se guarda como una respuesta con Status 0 que
+// sólo tiene el Fingerprint de la petición.
+type redisIdempotencyStore struct {
+	client *redis.Client
+}
+
+func newRedisIdempotencyStore(addr string) *redisIdempotencyStore {
+	return &redisIdempotencyStore{
+		client: redis.NewClient(&redis.Options{
+			Addr:         addr,
+			MinIdleConns: 2,
+			PoolSize:     10,
+			PoolTimeout:  5 * time.Second,
+		}),
+	}
+}
+
+func (s *redisIdempotencyStore) Reserve(ctx context.Context, key, fingerprint string, ttl time.Duration) (*cachedResponse, bool, error) {
+	pending, err := json.Marshal(&cachedResponse{Fingerprint: fingerprint})
+	if err != nil {
+		return nil, false, err
+	}
+	reserved, err := s.client.SetNX(ctx, redisKeyPrefix+key, pending, ttl).Result()
+	if err != nil || reserved {
+		return nil, reserved, err
+	}
+
+	value, err := s.client.Get(ctx, redisKeyPrefix+key).Result()
+	if err == redis.Nil {
+		// Expiró entre SETNX y GET: intentar reservar de nuevo
+		return s.Reserve(ctx, key, fingerprint, ttl)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var resp cachedResponse
+	if err := json.Unmarshal([]byte(value), &resp); err != nil {
+		return nil, false, err
+	}
+	if resp.Fingerprint != fingerprint {
+		return nil, false, errIdempotencyKeyReused
+	}
+	if resp.Status == 0 {
+		return nil, false, nil
+	}
+	return &resp, false, nil
+}
+
+func (s *redisIdempotencyStore) Complete(ctx context.Context, key string, resp *cachedResponse, ttl time.Duration) error {
+	value, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, redisKeyPrefix+key, value, ttl).Err()
+}
+
+func (s *redisIdempotencyStore) Release(ctx context.Context, key string) error {
+	return s.client.Del(ctx, redisKeyPrefix+key).Err()
+}
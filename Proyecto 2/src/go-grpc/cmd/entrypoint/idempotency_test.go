@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	type step struct {
+		op          string // reserve, complete o release
+		key         string
+		fingerprint string
+		ttl         time.Duration
+
+		wantReserved bool
+		wantCached   bool
+		wantErr      error
+	}
+	tests := []struct {
+		name     string
+		capacity int
+		steps    []step
+	}{
+		{
+			name:     "new key is reserved",
+			capacity: 10,
+			steps: []step{
+				{op: "reserve", key: "a", fingerprint: "f1", ttl: time.Minute, wantReserved: true},
+			},
+		},
+		{
+			name:     "key in progress",
+			capacity: 10,
+			steps: []step{
+				{op: "reserve", key: "a", fingerprint: "f1", ttl: time.Minute, wantReserved: true},
+				{op: "reserve", key: "a", fingerprint: "f1", ttl: time.Minute},
+			},
+		},
+		{
+			name:     "completed key replays",
+			capacity: 10,
+			steps: []step{
+				{op: "reserve", key: "a", fingerprint: "f1", ttl: time.Minute, wantReserved: true},
+				{op: "complete", key: "a", fingerprint: "f1", ttl: time.Minute},
+				{op: "reserve", key: "a", fingerprint: "f1", ttl: time.Minute, wantCached: true},
+			},
+		},
+		{
+			name:     "released key is reserved again",
+			capacity: 10,
+			steps: []step{
+				{op: "reserve", key: "a", fingerprint: "f1", ttl: time.Minute, wantReserved: true},
+				{op: "release", key: "a"},
+				{op: "reserve", key: "a", fingerprint: "f1", ttl: time.Minute, wantReserved: true},
+			},
+		},
+		{
+			name:     "reused key with another fingerprint",
+			capacity: 10,
+			steps: []step{
+				{op: "reserve", key: "a", fingerprint: "f1", ttl: time.Minute, wantReserved: true},
+				{op: "reserve", key: "a", fingerprint: "f2", ttl: time.Minute, wantErr: errIdempotencyKeyReused},
+				{op: "complete", key: "a", fingerprint: "f1", ttl: time.Minute},
+				{op: "reserve", key: "a", fingerprint: "f2", ttl: time.Minute, wantErr: errIdempotencyKeyReused},
+			},
+		},
+		{
+			name:     "expired key is reserved again",
+			capacity: 10,
+			steps: []step{
+				{op: "reserve", key: "a", fingerprint: "f1", ttl: time.Minute, wantReserved: true},
+				{op: "complete", key: "a", fingerprint: "f1", ttl: -time.Second},
+				{op: "reserve", key: "a", fingerprint: "f2", ttl: time.Minute, wantReserved: true},
+			},
+		},
+		{
+			name:     "completed keys are evicted first",
+			capacity: 2,
+			steps: []step{
+				{op: "reserve", key: "a", fingerprint: "f1", ttl: time.Minute, wantReserved: true},
+				{op: "reserve", key: "b", fingerprint: "f1", ttl: time.Minute, wantReserved: true},
+				{op: "complete", key: "b", fingerprint: "f1", ttl: time.Minute},
+				{op: "reserve", key: "c", fingerprint: "f1", ttl: time.Minute, wantReserved: true},
+				{op: "reserve", key: "b", fingerprint: "f1", ttl: time.Minute, wantReserved: true},
+			},
+		},
+		{
+			name:     "keys in progress are not evicted",
+			capacity: 1,
+			steps: []step{
+				{op: "reserve", key: "a", fingerprint: "f1", ttl: time.Minute, wantReserved: true},
+				{op: "reserve", key: "b", fingerprint: "f1", ttl: time.Minute, wantReserved: true},
+				{op: "reserve", key: "a", fingerprint: "f1", ttl: time.Minute},
+				{op: "reserve", key: "b", fingerprint: "f1", ttl: time.Minute},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryIdempotencyStore(tt.capacity)
+			ctx := context.Background()
+			for i, s := range tt.steps {
+				switch s.op {
+				case "reserve":
+					cached, reserved, err := store.Reserve(ctx, s.key, s.fingerprint, s.ttl)
+					if !errors.Is(err, s.wantErr) {
+						t.Fatalf("step %d: Reserve(%q) error = %v, want %v", i, s.key, err, s.wantErr)
+					}
+					if reserved != s.wantReserved || (cached != nil) != s.wantCached {
+						t.Fatalf("step %d: Reserve(%q) = (cached %v, reserved %v), want (cached %v, reserved %v)", i, s.key, cached != nil, reserved, s.wantCached, s.wantReserved)
+					}
+				case "complete":
+					resp := &cachedResponse{Status: http.StatusOK, Fingerprint: s.fingerprint}
+					if err := store.Complete(ctx, s.key, resp, s.ttl); err != nil {
+						t.Fatalf("step %d: Complete(%q): %v", i, s.key, err)
+					}
+				case "release":
+					if err := store.Release(ctx, s.key); err != nil {
+						t.Fatalf("step %d: Release(%q): %v", i, s.key, err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestWithIdempotency(t *testing.T) {
+	type request struct {
+		key  string
+		body string
+
+		wantStatus   int
+		wantReplayed bool
+	}
+	tests := []struct {
+		name      string
+		hashBody  bool
+		status    int // respuesta del handler
+		requests  []request
+		wantCalls int
+	}{
+		{
+			name:   "retry is replayed",
+			status: http.StatusOK,
+			requests: []request{
+				{key: "k", body: `{"a":1}`, wantStatus: http.StatusOK},
+				{key: "k", body: `{"a":1}`, wantStatus: http.StatusOK, wantReplayed: true},
+			},
+			wantCalls: 1,
+		},
+		{
+			name:   "key reused with another body",
+			status: http.StatusOK,
+			requests: []request{
+				{key: "k", body: `{"a":1}`, wantStatus: http.StatusOK},
+				{key: "k", body: `{"a":2}`, wantStatus: http.StatusUnprocessableEntity},
+			},
+			wantCalls: 1,
+		},
+		{
+			name:   "errors are not remembered",
+			status: http.StatusServiceUnavailable,
+			requests: []request{
+				{key: "k", body: `{"a":1}`, wantStatus: http.StatusServiceUnavailable},
+				{key: "k", body: `{"a":1}`, wantStatus: http.StatusServiceUnavailable},
+			},
+			wantCalls: 2,
+		},
+		{
+			name:   "no key and no body hashing",
+			status: http.StatusOK,
+			requests: []request{
+				{body: `{"a":1}`, wantStatus: http.StatusOK},
+				{body: `{"a":1}`, wantStatus: http.StatusOK},
+			},
+			wantCalls: 2,
+		},
+		{
+			name:     "body hashing without key",
+			hashBody: true,
+			status:   http.StatusOK,
+			requests: []request{
+				{body: `{"a":1}`, wantStatus: http.StatusOK},
+				{body: `{"a":1}`, wantStatus: http.StatusOK, wantReplayed: true},
+				{body: `{"a":2}`, wantStatus: http.StatusOK},
+			},
+			wantCalls: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			handler := withIdempotency(
+				idempotencyConfig{store: newMemoryIdempotencyStore(10), ttl: time.Minute, hashBody: tt.hashBody},
+				func(w http.ResponseWriter, r *http.Request) {
+					calls++
+					w.WriteHeader(tt.status)
+				},
+			)
+
+			for i, req := range tt.requests {
+				r := httptest.NewRequest(http.MethodPost, "/input", strings.NewReader(req.body))
+				if req.key != "" {
+					r.Header.Set(idempotencyKeyHeader, req.key)
+				}
+				w := httptest.NewRecorder()
+				handler(w, r)
+
+				if w.Code != req.wantStatus {
+					t.Errorf("request %d: status = %d, want %d", i, w.Code, req.wantStatus)
+				}
+				if replayed := w.Header().Get("Idempotent-Replayed") == "true"; replayed != req.wantReplayed {
+					t.Errorf("request %d: replayed = %v, want %v", i, replayed, req.wantReplayed)
+				}
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("handler calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
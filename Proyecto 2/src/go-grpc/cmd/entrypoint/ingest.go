@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"servidor-api-go/internal/proto"
+)
+
+// Estados de un reporte ingerido, comunes a HTTP y gRPC.
+const (
+	ingestAccepted = "accepted" // publicado en todos los writers del plan
+	ingestPartial  = "partial"  // quorum alcanzado, faltan copias
+	ingestSpooled  = "spooled"  // quorum no alcanzado, guardado en el spool
+	ingestFailed   = "failed"
+)
+
+// ingestResult es el resultado de ingerir un reporte ya validado.
+type ingestResult struct {
+	status   string
+	spooled  bool // las copias que faltan quedaron en el spool
+	spoolErr error
+	outcome  *publishOutcome
+}
+
+// err resume por qué falló el reporte, o nil si fue aceptado.
+func (r ingestResult) err() error {
+	if r.status != ingestFailed {
+		return nil
+	}
+	return errors.Join(r.outcome.err(), r.spoolErr)
+}
+
+// rejected indica que el reporte falló sólo porque los writers lo rechazaron:
+// reintentarlo no lo va a arreglar.
+func (r ingestResult) rejected() bool {
+	return r.status == ingestFailed && r.spoolErr == nil && r.outcome.rejected()
+}
+
+// ingest publica un reporte validado y sellado según plan (o la política
+// activa si plan es nil) y guarda en el spool lo que quede pendiente.
+func (f *fanout) ingest(ctx context.Context, tweet *proto.WeatherRequest, plan *routePlan) ingestResult {
+	var outcome *publishOutcome
+	if plan != nil {
+		outcome = f.publishPlan(ctx, tweet, *plan)
+	} else {
+		outcome = f.publish(ctx, tweet)
+	}
+	return f.settle(tweet, outcome)
+}
+
+// ingestBatch es ingest para varios reportes, usando las RPC de lote de los writers.
+func (f *fanout) ingestBatch(ctx context.Context, tweets []*proto.WeatherRequest, plan *routePlan) []ingestResult {
+	route := f.policy.Load().route
+	if plan != nil {
+		route = func(*proto.WeatherRequest) routePlan { return *plan }
+	}
+
+	results := make([]ingestResult, len(tweets))
+	for i, outcome := range f.publishBatch(ctx, tweets, route) {
+		results[i] = f.settle(tweets[i], outcome)
+	}
+	return results
+}
+
+// settle clasifica el resultado de publicar un reporte y guarda en el spool
+// las copias que falten.
+func (f *fanout) settle(tweet *proto.WeatherRequest, outcome *publishOutcome) ingestResult {
+	spooled, spoolErr := f.spoolIncomplete(tweet, outcome)
+	result := ingestResult{spooled: spooled, spoolErr: spoolErr, outcome: outcome}
+	switch {
+	case outcome.complete():
+		result.status = ingestAccepted
+	case outcome.ok():
+		result.status = ingestPartial
+	case spooled:
+		result.status = ingestSpooled
+	default:
+		result.status = ingestFailed
+	}
+	return result
+}
@@ -3,84 +3,67 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"time"
-	"net"
 	"os"
-	"sync"
-	"servidor-api-go/internal/proto"
+	"strings"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
-type grpcServer struct {
-	proto.UnimplementedWeatherServiceServer
-}
-
-func (s *grpcServer) PublishToRabbitMQ(ctx context.Context, tweet *proto.WeatherRequest) (*proto.WeatherResponse, error) {
-	log.Printf("Received tweet for RabbitMQ: %v", tweet)
-	return &proto.WeatherResponse{Success: true, Message: "Forwarded to RabbitMQ writer"}, nil
-}
-
-func (s *grpcServer) PublishToKafka(ctx context.Context, tweet *proto.WeatherRequest) (*proto.WeatherResponse, error) {
-	log.Printf("Received tweet for Kafka: %v", tweet)
-	return &proto.WeatherResponse{Success: true, Message: "Forwarded to Kafka writer"}, nil
-}
-
-func startGRPCServer() {
-	lis, err := net.Listen("tcp", ":50050")
-	if err != nil {
-		log.Fatalf("failed to listen: %v", err)
-	}
-
-	s := grpc.NewServer()
-	proto.RegisterWeatherServiceServer(s, &grpcServer{})
-
-	log.Printf("gRPC server listening at %v", lis.Addr())
-	if err := s.Serve(lis); err != nil {
-		log.Fatalf("failed to serve: %v", err)
-	}
-}
+// maxBodyBytes limita el tamaño del cuerpo aceptado en /input.
+const maxBodyBytes = 1 << 20
 
 func main() {
-	// Start gRPC server in a goroutine
-	go startGRPCServer()
-
 	// HTTP Server setup
+	// WRITER_ADDRS lista los writers separados por coma; por compatibilidad se
+	// usan KAFKA_WRITER_ADDR y RABBITMQ_WRITER_ADDR si no está definida.
 	kafkaAddr := getEnv("KAFKA_WRITER_ADDR", "go-kafka-writer:50051")
 	rabbitAddr := getEnv("RABBITMQ_WRITER_ADDR", "go-rabbitmq-writer:50052")
+	writerAddrs := strings.Split(getEnv("WRITER_ADDRS", kafkaAddr+","+rabbitAddr), ",")
 
-	kafkaConn := setupGRPCConn(kafkaAddr)
-	defer kafkaConn.Close()
+	writers := newFanout(writerAddrs)
+	defer writers.close()
+	go reloadRoutingOnSignal(writers)
 
-	rabbitConn := setupGRPCConn(rabbitAddr)
-	defer rabbitConn.Close()
+	writers.spool = newSpoolFromEnv()
+	if writers.spool != nil {
+		go writers.spool.replay(context.Background(), writers)
+	}
 
-	http.HandleFunc("/input", handleInput(kafkaConn, rabbitConn))
-	http.HandleFunc("/health", handleHealthCheck)
+	idempotency := newIdempotencyConfigFromEnv()
+
+	// Start gRPC server in a goroutine
+	go startGRPCServer(writers, idempotency)
+
+	http.HandleFunc("/input", withIdempotency(idempotency, handleInput(writers)))
+	http.HandleFunc("/input/batch", withIdempotency(idempotency, handleBatchInput(writers)))
+	http.HandleFunc("/routing", handleRouting(writers, getEnv("ROUTING_ADMIN_TOKEN", "")))
+	http.HandleFunc("/spool", handleSpool(writers.spool))
+	http.HandleFunc("/health", handleHealthCheck(writers))
+	http.HandleFunc("/ready", handleReady(writers))
 
 	log.Printf("HTTP server running on :8080")
 	log.Fatal(http.ListenAndServe(":8080", nil))
 }
 
-func setupGRPCConn(addr string) *grpc.ClientConn {
-	conn, err := grpc.Dial(addr,
+// setupGRPCConn crea el cliente sin esperar a que el writer responda; la
+// conexión se establece (y se restablece) en segundo plano. La espera entre
+// intentos se acota para notar pronto que un writer volvió.
+func setupGRPCConn(addr string) (*grpc.ClientConn, error) {
+	reconnect := backoff.DefaultConfig
+	reconnect.MaxDelay = 5 * time.Second
+	return grpc.NewClient(addr,
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithBlock(),
-		grpc.WithTimeout(5*time.Second))
-	if err != nil {
-		log.Fatalf("Failed to connect to %s: %v", addr, err)
-	}
-	return conn
+		grpc.WithConnectParams(grpc.ConnectParams{Backoff: reconnect, MinConnectTimeout: 5 * time.Second}))
 }
 
-func handleInput(kafkaConn, rabbitConn *grpc.ClientConn) http.HandlerFunc {
-	clientKafka := proto.NewWeatherServiceClient(kafkaConn)
-	clientRabbit := proto.NewWeatherServiceClient(rabbitConn)
-
-	
-
+func handleInput(f *fanout) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		log.Printf("--> Received HTTP request on %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
@@ -90,57 +73,77 @@ func handleInput(kafkaConn, rabbitConn *grpc.ClientConn) http.HandlerFunc {
 			return
 		}
 
-		var tweet proto.WeatherRequest
-		if err := json.NewDecoder(r.Body).Decode(&tweet); err != nil {
-			log.Printf("Invalid request body: %v", err)
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		if err != nil {
+			log.Printf("Failed to read request body: %v", err)
 			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
 
-		log.Printf("Processing tweet: %+v", tweet)
-
-		var wg sync.WaitGroup
-		wg.Add(2)
-		errChan := make(chan error, 2)
-
-		go func() {
-			defer wg.Done()
-			_, err := clientKafka.PublishToKafka(r.Context(), &tweet)
-			if err != nil {
-				errChan <- err
-				log.Printf("Kafka publish error: %v", err)
-			}else {
-				log.Printf("Kafka publish success: %v", tweet)
-			}
-		}()
-
-		go func() {
-			defer wg.Done()
-			_, err := clientRabbit.PublishToRabbitMQ(r.Context(), &tweet)
-			if err != nil {
-				errChan <- err
-				log.Printf("RabbitMQ publish error: %v", err)
-			}else {
-				log.Printf("RabbitMQ publish success: %v", tweet)
-			}
-		}()
-
-		wg.Wait()
-		close(errChan)
-
-		if len(errChan) > 0 {
-			http.Error(w, "Failed to process some messages", http.StatusInternalServerError)
+		tweet, errs := decodeReport(body)
+		if errs != nil {
+			log.Printf("Invalid request body: %v", errs)
+			writeValidationError(w, errs)
 			return
 		}
+		if err := validateRequest(tweet); err != nil {
+			log.Printf("Invalid tweet: %v", err)
+			writeValidationError(w, err.(*validationError))
+			return
+		}
+		stampRequest(tweet, "http")
+
+		log.Printf("Processing tweet: %v", tweet)
+
+		result := f.ingest(r.Context(), tweet, nil)
+
+		resp := inputResponse{Status: result.status, ID: tweet.GetId(), Spooled: result.spooled, publishOutcome: result.outcome}
+		code := http.StatusAccepted
+		switch {
+		case result.status == ingestPartial:
+			// Se alcanzó el quorum pero faltan copias: el cliente no debe reintentar
+			code = http.StatusMultiStatus
+		case result.status != ingestFailed:
+		case result.rejected():
+			// Los writers rechazaron el reporte: reintentarlo no lo va a arreglar
+			code = http.StatusUnprocessableEntity
+		case errors.Is(result.spoolErr, errSpoolFull):
+			code = http.StatusServiceUnavailable
+		default:
+			code = http.StatusBadGateway
+		}
 
-		w.WriteHeader(http.StatusAccepted)
-		json.NewEncoder(w).Encode(map[string]string{"status": "accepted"})
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(resp)
 	}
 }
 
-func handleHealthCheck(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("OK"))
+// inputResponse es la respuesta de /input: el estado global del reporte y el
+// resultado de cada writer.
+type inputResponse struct {
+	Status string `json:"status"` // "accepted", "partial", "spooled" o "failed"
+	ID     string `json:"id"`
+	// Spooled indica que las copias que faltan quedaron en el spool para reenviarse.
+	Spooled bool `json:"spooled,omitempty"`
+	*publishOutcome
+}
+
+// handleHealthCheck responde OK junto con el estado de la conexión y del
+// circuit breaker de cada writer. Que un writer esté caído no vuelve al
+// entrypoint no saludable; eso lo informa /ready.
+func handleHealthCheck(f *fanout) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "OK")
+		for _, b := range f.backends {
+			st := b.breaker.status()
+			fmt.Fprintf(w, "\nWriter %s (%s): %v, breaker %s, consecutive failures %d", b.label(), b.addr, b.connState(), st.state, st.failures)
+			if st.state != breakerClosed && st.lastError != "" {
+				fmt.Fprintf(w, ", last error: %s", st.lastError)
+			}
+		}
+	}
 }
 
 func getEnv(key, defaultValue string) string {
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"servidor-api-go/internal/proto"
+)
+
+// stampRequest completa la identidad y las marcas de tiempo del reporte antes
+// de publicarlo. Respeta el id, event_time y source que envíe el cliente;
+// ingested_at siempre lo fija el entrypoint.
+func stampRequest(tweet *proto.WeatherRequest, source string) {
+	now := timestamppb.New(time.Now())
+
+	if tweet.GetId() == "" {
+		tweet.Id = newID()
+	}
+	if tweet.GetEventTime() == nil {
+		tweet.EventTime = now
+	}
+	tweet.IngestedAt = now
+	if tweet.GetSource() == "" {
+		tweet.Source = source
+	}
+}
+
+// newID genera un UUID versión 4.
+func newID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/status"
+)
+
+// Estados de un writer dentro de publishOutcome.
+const (
+	statusPublished = "published"
+	statusFailed    = "failed"
+)
+
+// writeQuorum indica cuántos writers del plan deben confirmar un reporte para
+// aceptarlo: todos ("all"), al menos uno ("any") o un número fijo N.
+type writeQuorum struct {
+	mode string // "all", "any" o "n"
+	n    int
+}
+
+// parseWriteQuorum interpreta WRITE_QUORUM: "all", "any" o un entero positivo.
+func parseWriteQuorum(value string) (writeQuorum, error) {
+	switch value {
+	case "all", "any":
+		return writeQuorum{mode: value}, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return writeQuorum{}, fmt.Errorf("%q is not all, any or a positive number", value)
+	}
+	return writeQuorum{mode: "n", n: n}, nil
+}
+
+// required devuelve cuántas confirmaciones hacen falta entre targets writers.
+// Sin writers disponibles hace falta una, así un reporte sin destino nunca se
+// da por aceptado.
+func (q writeQuorum) required(targets int) int {
+	switch q.mode {
+	case "any":
+		return 1
+	case "n":
+		return max(min(q.n, targets), 1)
+	}
+	return max(targets, 1)
+}
+
+func (q writeQuorum) String() string {
+	if q.mode == "n" {
+		return strconv.Itoa(q.n)
+	}
+	return q.mode
+}
+
+// backendResult es el resultado de publicar un reporte en un writer.
+type backendResult struct {
+	Backend   string  `json:"backend"`
+	Status    string  `json:"status"` // "published" o "failed"
+	LatencyMs float64 `json:"latency_ms"`
+	// Code es el código que informó el writer o, si la llamada gRPC falló, el código gRPC.
+	Code     string `json:"code,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Fallback bool   `json:"fallback,omitempty"` // publicado en un writer de respaldo
+}
+
+func newBackendResult(backend string, latency time.Duration, err error, success bool, code, message string) backendResult {
+	result := backendResult{
+		Backend:   backend,
+		Status:    statusPublished,
+		LatencyMs: float64(latency.Microseconds()) / 1000,
+	}
+	switch {
+	case errors.Is(err, errCircuitOpen):
+		result.Status = statusFailed
+		result.Code = codeCircuitOpen
+		result.Error = err.Error()
+	case err != nil:
+		result.Status = statusFailed
+		result.Code = status.Code(err).String()
+		result.Error = err.Error()
+	case !success:
+		result.Status = statusFailed
+		result.Code = code
+		result.Error = message
+	}
+	return result
+}
+
+// publishOutcome reúne el resultado de publicar un reporte en los writers de su plan.
+type publishOutcome struct {
+	Quorum   string          `json:"quorum"`
+	Required int             `json:"required"`
+	Backends []backendResult `json:"backends"`
+
+	targets []string // writers del plan, sin contar los de respaldo
+}
+
+// published cuenta las copias confirmadas, incluidas las de respaldo.
+func (o *publishOutcome) published() int {
+	n := 0
+	for _, result := range o.Backends {
+		if result.Status == statusPublished {
+			n++
+		}
+	}
+	return n
+}
+
+// ok indica si se alcanzó el quorum.
+func (o *publishOutcome) ok() bool {
+	return o.published() >= o.Required
+}
+
+// complete indica si el reporte tiene todas las copias que pedía el plan.
+func (o *publishOutcome) complete() bool {
+	return len(o.targets) > 0 && o.published() >= len(o.targets)
+}
+
+// pending devuelve los writers del plan que no recibieron el reporte cuando
+// faltan copias. Una lista vacía con el reporte incompleto significa que hay
+// que volver a enrutarlo.
+func (o *publishOutcome) pending() []string {
+	if o.complete() {
+		return nil
+	}
+	var names []string
+	for _, result := range o.Backends {
+		if result.Status == statusFailed && !result.Fallback {
+			names = append(names, result.Backend)
+		}
+	}
+	return names
+}
+
+// rejected indica que el reporte falló sólo porque los writers lo rechazaron,
+// sin ningún fallo pasajero que pueda resolverse al reintentar.
+func (o *publishOutcome) rejected() bool {
+	failed := false
+	for _, result := range o.Backends {
+		if result.Status != statusFailed {
+			continue
+		}
+		if isTransientResult(result.Code) {
+			return false
+		}
+		failed = true
+	}
+	return failed
+}
+
+// err resume los fallos de los writers, o nil si no hubo ninguno.
+func (o *publishOutcome) err() error {
+	if len(o.targets) == 0 {
+		return errors.New("no writers available")
+	}
+	var errs []error
+	for _, result := range o.Backends {
+		if result.Status == statusFailed {
+			errs = append(errs, fmt.Errorf("%s: %s", result.Backend, result.Error))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseWriteQuorum(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    writeQuorum
+		wantErr bool
+	}{
+		{value: "all", want: writeQuorum{mode: "all"}},
+		{value: "any", want: writeQuorum{mode: "any"}},
+		{value: "2", want: writeQuorum{mode: "n", n: 2}},
+		{value: "0", wantErr: true},
+		{value: "-1", wantErr: true},
+		{value: "ALL", wantErr: true},
+		{value: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseWriteQuorum(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseWriteQuorum(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseWriteQuorum(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteQuorumRequired(t *testing.T) {
+	tests := []struct {
+		quorum  string
+		targets int
+		want    int
+	}{
+		{"all", 2, 2},
+		{"all", 0, 1},
+		{"any", 2, 1},
+		{"any", 0, 1},
+		{"2", 3, 2},
+		{"3", 2, 2},
+		{"2", 0, 1},
+	}
+	for _, tt := range tests {
+		q, err := parseWriteQuorum(tt.quorum)
+		if err != nil {
+			t.Fatalf("parseWriteQuorum(%q): %v", tt.quorum, err)
+		}
+		if got := q.required(tt.targets); got != tt.want {
+			t.Errorf("quorum %s with %d targets: required = %d, want %d", tt.quorum, tt.targets, got, tt.want)
+		}
+	}
+}
+
+func TestPublishOutcome(t *testing.T) {
+	published := func(backend string) backendResult {
+		return backendResult{Backend: backend, Status: statusPublished}
+	}
+	failed := func(backend, code string) backendResult {
+		return backendResult{Backend: backend, Status: statusFailed, Code: code, Error: "failed"}
+	}
+	fallback := func(result backendResult) backendResult {
+		result.Fallback = true
+		return result
+	}
+
+	tests := []struct {
+		name     string
+		required int
+		targets  []string
+		backends []backendResult
+
+		wantOK       bool
+		wantComplete bool
+		wantPending  []string
+		wantRejected bool
+		wantErr      bool
+	}{
+		{
+			name:         "all published",
+			required:     2,
+			targets:      []string{"kafka", "rabbitmq"},
+			backends:     []backendResult{published("kafka"), published("rabbitmq")},
+			wantOK:       true,
+			wantComplete: true,
+		},
+		{
+			name:        "quorum reached with a missing copy",
+			required:    1,
+			targets:     []string{"kafka", "rabbitmq"},
+			backends:    []backendResult{published("kafka"), failed("rabbitmq", "Unavailable")},
+			wantOK:      true,
+			wantPending: []string{"rabbitmq"},
+			wantErr:     true,
+		},
+		{
+			name:        "quorum missed",
+			required:    2,
+			targets:     []string{"kafka", "rabbitmq"},
+			backends:    []backendResult{published("kafka"), failed("rabbitmq", "PUBLISH_FAILED")},
+			wantPending: []string{"rabbitmq"},
+			wantErr:     true,
+		},
+		{
+			name:         "copy published on a fallback",
+			required:     1,
+			targets:      []string{"kafka"},
+			backends:     []backendResult{failed("kafka", "Unavailable"), fallback(published("rabbitmq"))},
+			wantOK:       true,
+			wantComplete: true,
+			wantErr:      true,
+		},
+		{
+			name:         "failed fallbacks are not pending",
+			required:     1,
+			targets:      []string{"kafka"},
+			backends:     []backendResult{failed("kafka", "RETURNED"), fallback(failed("rabbitmq", "MARSHAL_FAILED"))},
+			wantPending:  []string{"kafka"},
+			wantRejected: true,
+			wantErr:      true,
+		},
+		{
+			name:         "rejected by every writer",
+			required:     2,
+			targets:      []string{"kafka", "rabbitmq"},
+			backends:     []backendResult{failed("kafka", "MARSHAL_FAILED"), failed("rabbitmq", "RETURNED")},
+			wantPending:  []string{"kafka", "rabbitmq"},
+			wantRejected: true,
+			wantErr:      true,
+		},
+		{
+			name:        "a transient failure is not a rejection",
+			required:    2,
+			targets:     []string{"kafka", "rabbitmq"},
+			backends:    []backendResult{failed("kafka", "MARSHAL_FAILED"), failed("rabbitmq", codeCircuitOpen)},
+			wantPending: []string{"kafka", "rabbitmq"},
+			wantErr:     true,
+		},
+		{
+			name:     "no writers",
+			required: 1,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &publishOutcome{Required: tt.required, Backends: tt.backends, targets: tt.targets}
+			if got := o.ok(); got != tt.wantOK {
+				t.Errorf("ok() = %v, want %v", got, tt.wantOK)
+			}
+			if got := o.complete(); got != tt.wantComplete {
+				t.Errorf("complete() = %v, want %v", got, tt.wantComplete)
+			}
+			if got := o.pending(); !reflect.DeepEqual(got, tt.wantPending) {
+				t.Errorf("pending() = %v, want %v", got, tt.wantPending)
+			}
+			if got := o.rejected(); got != tt.wantRejected {
+				t.Errorf("rejected() = %v, want %v", got, tt.wantRejected)
+			}
+			if err := o.err(); (err != nil) != tt.wantErr {
+				t.Errorf("err() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/connectivity"
+)
+
+// readiness indica qué writers deben estar conectados para que el entrypoint
+// esté listo: todos ("all"), al menos uno ("any") o los nombrados en names.
+type readiness struct {
+	mode  string
+	names []string
+}
+
+// parseReadiness interpreta READY_WRITERS: "all", "any" o una lista de
+// writers (por nombre o dirección) separados por coma. El valor por defecto
+// es "any": con un writer caído el entrypoint sigue aceptando reportes con
+// WRITE_QUORUM y el spool, así que no debe dejar de recibir tráfico.
+func parseReadiness(value string) readiness {
+	switch value {
+	case "all", "any":
+		return readiness{mode: value}
+	}
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return readiness{mode: "list", names: names}
+}
+
+// isReady evalúa la condición de readiness con el estado actual de las conexiones.
+func (f *fanout) isReady() bool {
+	connected := 0
+	for _, b := range f.backends {
+		if b.connState() == connectivity.Ready {
+			connected++
+		}
+	}
+
+	switch f.ready.mode {
+	case "any":
+		return connected > 0
+	case "list":
+		for _, name := range f.ready.names {
+			b := f.lookup(name)
+			if b == nil || b.connState() != connectivity.Ready {
+				return false
+			}
+		}
+		return true
+	}
+	return connected == len(f.backends)
+}
+
+// handleReady responde 200 cuando los writers requeridos están conectados y
+// 503 mientras no lo estén, con el estado de cada conexión.
+func handleReady(f *fanout) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if f.isReady() {
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprint(w, "READY")
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, "NOT READY")
+		}
+		for _, b := range f.backends {
+			fmt.Fprintf(w, "\nWriter %s (%s): %v", b.label(), b.addr, b.connState())
+		}
+	}
+}
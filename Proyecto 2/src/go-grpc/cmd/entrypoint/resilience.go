@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// codeCircuitOpen es el código de los reportes rechazados sin llamar al writer.
+const codeCircuitOpen = "CIRCUIT_OPEN"
+
+var errCircuitOpen = status.Error(codes.Unavailable, "circuit breaker open")
+
+// transientCodes son los códigos de los writers que indican un fallo pasajero
+// del broker; los demás (por ejemplo RETURNED o MARSHAL_FAILED) no mejoran al
+// reintentar.
+var transientCodes = map[string]bool{
+	"PRODUCE_FAILED":      true,
+	"DELIVERY_FAILED":     true,
+	"CHANNEL_UNAVAILABLE": true,
+	"PUBLISH_FAILED":      true,
+	"NACKED":              true,
+}
+
+// ambiguousCodes son los fallos pasajeros en los que el broker pudo haber
+// aceptado el reporte igual: la entrega o la confirmación no llegaron a
+// tiempo, o el broker no pudo garantizarla. No se reintentan, porque los
+// consumidores no deduplican por id y el reporte se contaría dos veces.
+var ambiguousCodes = map[string]bool{
+	"DELIVERY_FAILED": true,
+	"PUBLISH_FAILED":  true,
+	"NACKED":          true,
+}
+
+// isTransient indica si una llamada que terminó con err o, si la llamada
+// funcionó, con el código de fallo code del writer, muestra un writer o un
+// broker con problemas.
+func isTransient(code string, err error) bool {
+	if err != nil {
+		switch status.Code(err) {
+		case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+			return true
+		}
+		return false
+	}
+	return transientCodes[code]
+}
+
+// isRetryable indica si vale la pena repetir la llamada: el fallo es pasajero
+// y el reporte seguro no se publicó. Un plazo vencido es ambiguo, el writer
+// pudo haber publicado antes de que se cortara la llamada.
+func isRetryable(code string, err error) bool {
+	if status.Code(err) == codes.DeadlineExceeded || ambiguousCodes[code] {
+		return false
+	}
+	return isTransient(code, err)
+}
+
+// isTransientResult indica si el código de un backendResult fallido es un
+// fallo pasajero del writer o de su broker y no un rechazo del reporte.
+func isTransientResult(code string) bool {
+	switch code {
+	case codeCircuitOpen, codes.Unavailable.String(), codes.DeadlineExceeded.String(),
+		codes.ResourceExhausted.String(), codes.Aborted.String(), codes.Canceled.String():
+		return true
+	}
+	return transientCodes[code]
+}
+
+// callPolicy controla los reintentos y el plazo de cada llamada a un writer.
+type callPolicy struct {
+	attempts       int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	timeout        time.Duration // plazo de cada intento
+}
+
+// newCallPolicyFromEnv lee RETRY_MAX_ATTEMPTS, RETRY_INITIAL_BACKOFF,
+// RETRY_MAX_BACKOFF y CALL_TIMEOUT. Cada valor se puede sobrescribir por
+// writer anteponiendo su nombre, por ejemplo KAFKA_CALL_TIMEOUT.
+func newCallPolicyFromEnv(backend string) callPolicy {
+	return callPolicy{
+		attempts:       backendEnvInt(backend, "RETRY_MAX_ATTEMPTS", 3),
+		initialBackoff: backendEnvDuration(backend, "RETRY_INITIAL_BACKOFF", 100*time.Millisecond),
+		maxBackoff:     backendEnvDuration(backend, "RETRY_MAX_BACKOFF", 2*time.Second),
+		timeout:        backendEnvDuration(backend, "CALL_TIMEOUT", 5*time.Second),
+	}
+}
+
+// backoff devuelve la espera antes del intento attempt+1: exponencial con
+// jitter completo, acotada por maxBackoff.
+func (p callPolicy) backoff(attempt int) time.Duration {
+	limit := p.initialBackoff << (attempt - 1)
+	if limit <= 0 || limit > p.maxBackoff {
+		limit = p.maxBackoff
+	}
+	return rand.N(limit + 1)
+}
+
+// Estados del circuit breaker.
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half-open"
+)
+
+// circuitBreaker deja de llamar a un writer tras threshold fallos seguidos.
+// Pasado cooldown deja pasar una sola llamada de prueba: si funciona se
+// cierra, si falla vuelve a abrirse.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	state     string
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	lastError string
+}
+
+func newCircuitBreakerFromEnv(backend string) *circuitBreaker {
+	cb := &circuitBreaker{state: breakerClosed}
+	cb.configure(backend)
+	return cb
+}
+
+// configure lee BREAKER_FAILURES y BREAKER_COOLDOWN para el writer, con la
+// misma convención de prefijo que newCallPolicyFromEnv.
+func (cb *circuitBreaker) configure(backend string) {
+	threshold := backendEnvInt(backend, "BREAKER_FAILURES", 5)
+	cooldown := backendEnvDuration(backend, "BREAKER_COOLDOWN", 10*time.Second)
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.threshold = threshold
+	cb.cooldown = cooldown
+}
+
+// allow indica si se puede llamar al writer. En half-open sólo autoriza la
+// llamada de prueba.
+func (cb *circuitBreaker) allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case breakerOpen:
+		if time.Since(cb.openedAt) < cb.cooldown {
+			return false
+		}
+		cb.state = breakerHalfOpen
+		cb.probing = false
+		fallthrough
+	case breakerHalfOpen:
+		if cb.probing {
+			return false
+		}
+		cb.probing = true
+	}
+	return true
+}
+
+// record registra el resultado de una llamada autorizada por allow.
+func (cb *circuitBreaker) record(backend string, err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if err == nil {
+		if cb.state != breakerClosed {
+			log.Printf("Circuit breaker for %s closed", backend)
+		}
+		cb.state = breakerClosed
+		cb.failures = 0
+		cb.probing = false
+		return
+	}
+
+	cb.failures++
+	cb.lastError = err.Error()
+	if cb.state == breakerHalfOpen || cb.failures >= cb.threshold {
+		if cb.state != breakerOpen {
+			log.Printf("Circuit breaker for %s opened after %d failures: %v", backend, cb.failures, err)
+		}
+		cb.state = breakerOpen
+		cb.openedAt = time.Now()
+		cb.probing = false
+	}
+}
+
+// probe pasa un breaker abierto a half-open sin esperar el cooldown, por
+// ejemplo porque la conexión con el writer se acaba de restablecer.
+func (cb *circuitBreaker) probe() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == breakerOpen {
+		cb.state = breakerHalfOpen
+		cb.probing = false
+	}
+}
+
+// abort libera una llamada autorizada por allow cuyo resultado no dice nada
+// del writer, por ejemplo porque el cliente la canceló.
+func (cb *circuitBreaker) abort() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.probing = false
+}
+
+// breakerStatus es el estado de un breaker que se muestra en /health.
+type breakerStatus struct {
+	state     string
+	failures  int
+	lastError string
+}
+
+func (cb *circuitBreaker) status() breakerStatus {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	state := cb.state
+	if state == breakerOpen && time.Since(cb.openedAt) >= cb.cooldown {
+		state = breakerHalfOpen
+	}
+	return breakerStatus{state: state, failures: cb.failures, lastError: cb.lastError}
+}
+
+// invoke llama a op a través del breaker del writer, con un plazo por intento
+// y reintentos con backoff mientras el fallo sea pasajero y no ambiguo (ver
+// isRetryable). op devuelve el código de fallo del writer (vacío si publicó)
+// o el error de la llamada.
+func (b *backend) invoke(ctx context.Context, op func(context.Context) (string, error)) (code string, err error) {
+	if !b.breaker.allow() {
+		return codeCircuitOpen, errCircuitOpen
+	}
+
+	name, policy := b.label(), b.callPolicy()
+	for attempt := 1; ; attempt++ {
+		callCtx, cancel := context.WithTimeout(ctx, policy.timeout)
+		code, err = op(callCtx)
+		cancel()
+
+		if !isRetryable(code, err) || attempt >= policy.attempts || ctx.Err() != nil {
+			break
+		}
+		wait := policy.backoff(attempt)
+		log.Printf("%s call failed (attempt %d/%d, code %q, err %v), retrying in %v", name, attempt, policy.attempts, code, err, wait)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	// Sólo los fallos pasajeros indican un writer caído; si el cliente canceló
+	// no se sabe nada del writer
+	if ctx.Err() != nil && (err != nil || code != "") {
+		b.breaker.abort()
+	} else if isTransient(code, err) {
+		failure := err
+		if failure == nil {
+			failure = errors.New(code)
+		}
+		b.breaker.record(name, failure)
+	} else {
+		b.breaker.record(name, nil)
+	}
+	return code, err
+}
+
+// backendEnvInt lee <BACKEND>_<key> o, si no existe, <key>.
+func backendEnvInt(backend, key string, def int) int {
+	value := backendEnv(backend, key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		log.Fatalf("Invalid %s for %s: %q", key, backend, value)
+	}
+	return n
+}
+
+// backendEnvDuration lee <BACKEND>_<key> o, si no existe, <key>.
+func backendEnvDuration(backend, key string, def time.Duration) time.Duration {
+	value := backendEnv(backend, key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Fatalf("Invalid %s for %s: %q", key, backend, value)
+	}
+	return d
+}
+
+func backendEnv(backend, key string) string {
+	prefix := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_", ":", "_").Replace(backend))
+	return getEnv(prefix+"_"+key, getEnv(key, ""))
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		err  error
+		want bool
+	}{
+		{name: "writer unavailable", err: status.Error(codes.Unavailable, "down"), want: true},
+		{name: "resource exhausted", err: status.Error(codes.ResourceExhausted, "busy"), want: true},
+		{name: "aborted", err: status.Error(codes.Aborted, "aborted"), want: true},
+		{name: "produce failed", code: "PRODUCE_FAILED", want: true},
+		{name: "channel unavailable", code: "CHANNEL_UNAVAILABLE", want: true},
+		// Ambiguos: el reporte pudo haberse publicado
+		{name: "deadline exceeded", err: status.Error(codes.DeadlineExceeded, "timeout")},
+		{name: "delivery failed", code: "DELIVERY_FAILED"},
+		{name: "publish failed", code: "PUBLISH_FAILED"},
+		{name: "nacked", code: "NACKED"},
+		// Rechazos: reintentar no cambia nada
+		{name: "marshal failed", code: "MARSHAL_FAILED"},
+		{name: "returned", code: "RETURNED"},
+		{name: "invalid argument", err: status.Error(codes.InvalidArgument, "bad")},
+		{name: "published"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryable(tt.code, tt.err); got != tt.want {
+				t.Errorf("isRetryable(%q, %v) = %v, want %v", tt.code, tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	type step struct {
+		op        string // allow, fail, succeed, abort, cooldown (pasa el cooldown) o probe
+		wantAllow bool   // sólo para allow
+		wantState string
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "opens after threshold failures",
+			steps: []step{
+				{op: "allow", wantAllow: true, wantState: breakerClosed},
+				{op: "fail", wantState: breakerClosed},
+				{op: "allow", wantAllow: true, wantState: breakerClosed},
+				{op: "fail", wantState: breakerOpen},
+				{op: "allow", wantState: breakerOpen},
+			},
+		},
+		{
+			name: "a success resets the failures",
+			steps: []step{
+				{op: "fail", wantState: breakerClosed},
+				{op: "succeed", wantState: breakerClosed},
+				{op: "fail", wantState: breakerClosed},
+				{op: "allow", wantAllow: true, wantState: breakerClosed},
+			},
+		},
+		{
+			name: "half-open lets a single probe through and closes",
+			steps: []step{
+				{op: "fail"},
+				{op: "fail", wantState: breakerOpen},
+				{op: "cooldown", wantState: breakerOpen},
+				{op: "allow", wantAllow: true, wantState: breakerHalfOpen},
+				{op: "allow", wantState: breakerHalfOpen},
+				{op: "allow", wantState: breakerHalfOpen},
+				{op: "succeed", wantState: breakerClosed},
+				{op: "allow", wantAllow: true, wantState: breakerClosed},
+				{op: "allow", wantAllow: true, wantState: breakerClosed},
+			},
+		},
+		{
+			name: "failed probe opens again",
+			steps: []step{
+				{op: "fail"},
+				{op: "fail", wantState: breakerOpen},
+				{op: "cooldown", wantState: breakerOpen},
+				{op: "allow", wantAllow: true, wantState: breakerHalfOpen},
+				{op: "fail", wantState: breakerOpen},
+				{op: "allow", wantState: breakerOpen},
+			},
+		},
+		{
+			name: "aborted probe frees the slot",
+			steps: []step{
+				{op: "fail"},
+				{op: "fail", wantState: breakerOpen},
+				{op: "cooldown", wantState: breakerOpen},
+				{op: "allow", wantAllow: true, wantState: breakerHalfOpen},
+				{op: "abort", wantState: breakerHalfOpen},
+				{op: "allow", wantAllow: true, wantState: breakerHalfOpen},
+				{op: "allow", wantState: breakerHalfOpen},
+			},
+		},
+		{
+			name: "probe skips the cooldown",
+			steps: []step{
+				{op: "fail"},
+				{op: "fail", wantState: breakerOpen},
+				{op: "probe", wantState: breakerHalfOpen},
+				{op: "allow", wantAllow: true, wantState: breakerHalfOpen},
+				{op: "allow", wantState: breakerHalfOpen},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := &circuitBreaker{state: breakerClosed, threshold: 2, cooldown: time.Hour}
+			for i, s := range tt.steps {
+				switch s.op {
+				case "allow":
+					if got := cb.allow(); got != s.wantAllow {
+						t.Fatalf("step %d: allow() = %v, want %v", i, got, s.wantAllow)
+					}
+				case "fail":
+					cb.record("kafka", errors.New("down"))
+				case "succeed":
+					cb.record("kafka", nil)
+				case "abort":
+					cb.abort()
+				case "cooldown":
+					cb.openedAt = cb.openedAt.Add(-cb.cooldown)
+				case "probe":
+					cb.probe()
+				}
+				if s.wantState != "" && cb.state != s.wantState {
+					t.Fatalf("step %d (%s): state = %s, want %s", i, s.op, cb.state, s.wantState)
+				}
+			}
+		})
+	}
+}
+
+func TestBackendInvoke(t *testing.T) {
+	type result struct {
+		code string
+		err  error
+	}
+	unavailable := result{err: status.Error(codes.Unavailable, "down")}
+	tests := []struct {
+		name    string
+		results []result // lo que devuelve cada intento; después, publicado
+		open    bool     // breaker abierto antes de llamar
+
+		wantCalls    int
+		wantCode     string
+		wantErr      codes.Code
+		wantFailures int
+	}{
+		{
+			name:      "published",
+			wantCalls: 1,
+		},
+		{
+			name:      "unavailable is retried",
+			results:   []result{unavailable},
+			wantCalls: 2,
+		},
+		{
+			name:         "retries stop at attempts",
+			results:      []result{unavailable, unavailable, unavailable, unavailable},
+			wantCalls:    3,
+			wantErr:      codes.Unavailable,
+			wantFailures: 1,
+		},
+		{
+			name:         "deadline exceeded is not retried",
+			results:      []result{{err: status.Error(codes.DeadlineExceeded, "timeout")}},
+			wantCalls:    1,
+			wantErr:      codes.DeadlineExceeded,
+			wantFailures: 1,
+		},
+		{
+			name:         "delivery failed is not retried",
+			results:      []result{{code: "DELIVERY_FAILED"}},
+			wantCalls:    1,
+			wantCode:     "DELIVERY_FAILED",
+			wantFailures: 1,
+		},
+		{
+			name:         "nacked is not retried",
+			results:      []result{{code: "NACKED"}},
+			wantCalls:    1,
+			wantCode:     "NACKED",
+			wantFailures: 1,
+		},
+		{
+			name:         "publish failed is not retried",
+			results:      []result{{code: "PUBLISH_FAILED"}},
+			wantCalls:    1,
+			wantCode:     "PUBLISH_FAILED",
+			wantFailures: 1,
+		},
+		{
+			name:      "rejection does not count against the writer",
+			results:   []result{{code: "MARSHAL_FAILED"}},
+			wantCalls: 1,
+			wantCode:  "MARSHAL_FAILED",
+		},
+		{
+			name:     "open breaker skips the call",
+			open:     true,
+			wantCode: codeCircuitOpen,
+			wantErr:  codes.Unavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBackend("kafka", &fakeWriter{accept: -1})
+			b.policy.attempts = 3
+			if tt.open {
+				b.breaker.state = breakerOpen
+				b.breaker.openedAt = time.Now()
+			}
+
+			calls := 0
+			code, err := b.invoke(context.Background(), func(context.Context) (string, error) {
+				calls++
+				if calls > len(tt.results) {
+					return "", nil
+				}
+				return tt.results[calls-1].code, tt.results[calls-1].err
+			})
+
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if code != tt.wantCode || status.Code(err) != tt.wantErr {
+				t.Errorf("invoke() = (%q, %v), want (%q, %v)", code, err, tt.wantCode, tt.wantErr)
+			}
+			if failures := b.breaker.status().failures; failures != tt.wantFailures {
+				t.Errorf("breaker failures = %d, want %d", failures, tt.wantFailures)
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+
+	"servidor-api-go/internal/message"
+	"servidor-api-go/internal/proto"
+)
+
+// Modos de enrutamiento soportados.
+const (
+	routeBroadcast       = "broadcast"        // todos los writers (comportamiento original)
+	routePrimaryFallback = "primary-fallback" // el primario y, si falla, los de respaldo en orden
+	routeRoundRobin      = "round-robin"      // un writer por reporte, rotando
+	routeWeighted        = "weighted"         // un writer por reporte, elegido según pesos
+	routeRules           = "rules"            // según country o weather del reporte
+)
+
+// routingConfig describe la política de enrutamiento. Los writers se nombran
+// por el backend que informan en Describe ("kafka", "rabbitmq") o por su dirección.
+//
+//	{"mode": "broadcast"}
+//	{"mode": "primary-fallback", "primary": "kafka", "fallbacks": ["rabbitmq"]}
+//	{"mode": "round-robin", "backends": ["kafka", "rabbitmq"]}
+//	{"mode": "weighted", "weights": {"kafka": 80, "rabbitmq": 20}}
+//	{"mode": "rules", "rules": [{"field": "country", "values": ["GT"], "backends": ["kafka"]}], "default": ["rabbitmq"]}
+type routingConfig struct {
+	Mode      string         `json:"mode"`
+	Backends  []string       `json:"backends,omitempty"`
+	Primary   string         `json:"primary,omitempty"`
+	Fallbacks []string       `json:"fallbacks,omitempty"`
+	Weights   map[string]int `json:"weights,omitempty"`
+	Rules     []routingRule  `json:"rules,omitempty"`
+	Default   []string       `json:"default,omitempty"`
+}
+
+// routingRule envía a Backends los reportes cuyo campo coincide con alguno de Values.
+type routingRule struct {
+	Field    string   `json:"field"` // "country" o "weather"
+	Values   []string `json:"values"`
+	Backends []string `json:"backends"`
+}
+
+// routePlan indica a qué writers publicar un reporte. Targets reciben el
+// reporte en paralelo; si alguno falla se prueba Fallbacks en orden hasta que
+// uno lo acepte. Una lista vacía de Targets significa todos los writers.
+type routePlan struct {
+	targets   []string
+	fallbacks []string
+}
+
+// routingPolicy es una configuración ya validada, lista para enrutar.
+type routingPolicy struct {
+	config routingConfig
+	next   atomic.Uint64 // turno para round-robin
+	total  int           // suma de pesos para weighted
+}
+
+func newRoutingPolicy(cfg routingConfig) (*routingPolicy, error) {
+	p := &routingPolicy{config: cfg}
+
+	switch cfg.Mode {
+	case routeBroadcast:
+	case routePrimaryFallback:
+		if cfg.Primary == "" {
+			return nil, errors.New("primary-fallback requires a primary")
+		}
+	case routeRoundRobin:
+		if len(cfg.Backends) == 0 {
+			return nil, errors.New("round-robin requires backends")
+		}
+	case routeWeighted:
+		for name, weight := range cfg.Weights {
+			if weight < 0 {
+				return nil, fmt.Errorf("negative weight for %s", name)
+			}
+			p.total += weight
+		}
+		if p.total == 0 {
+			return nil, errors.New("weighted requires at least one positive weight")
+		}
+	case routeRules:
+		for i, rule := range cfg.Rules {
+			if rule.Field != "country" && rule.Field != "weather" {
+				return nil, fmt.Errorf("rule %d: unknown field %q", i, rule.Field)
+			}
+			if len(rule.Values) == 0 {
+				return nil, fmt.Errorf("rule %d: no values", i)
+			}
+			if len(rule.Backends) == 0 {
+				return nil, fmt.Errorf("rule %d: no backends", i)
+			}
+		}
+		// Un default vacío significaría todos los writers: hay que pedirlo
+		// explícitamente, así una política de reglas nunca hace broadcast sin querer
+		if len(cfg.Default) == 0 {
+			return nil, errors.New("rules requires a default (list every writer to broadcast)")
+		}
+	default:
+		return nil, fmt.Errorf("unknown routing mode %q", cfg.Mode)
+	}
+	return p, nil
+}
+
+// route decide el plan de publicación de un reporte.
+func (p *routingPolicy) route(tweet *proto.WeatherRequest) routePlan {
+	cfg := p.config
+	switch cfg.Mode {
+	case routePrimaryFallback:
+		return routePlan{targets: []string{cfg.Primary}, fallbacks: cfg.Fallbacks}
+	case routeRoundRobin:
+		n := p.next.Add(1) - 1
+		return routePlan{targets: []string{cfg.Backends[n%uint64(len(cfg.Backends))]}}
+	case routeWeighted:
+		pick := rand.IntN(p.total)
+		for name, weight := range cfg.Weights {
+			if pick < weight {
+				return routePlan{targets: []string{name}}
+			}
+			pick -= weight
+		}
+	case routeRules:
+		for _, rule := range cfg.Rules {
+			value := tweet.GetCountry()
+			if rule.Field == "weather" {
+				value = message.Weather(tweet.GetWeatherType()).String()
+			}
+			for _, candidate := range rule.Values {
+				if strings.EqualFold(candidate, value) {
+					return routePlan{targets: rule.Backends}
+				}
+			}
+		}
+		return routePlan{targets: cfg.Default}
+	}
+	return routePlan{targets: cfg.Backends}
+}
+
+// loadRoutingConfig lee la política desde ROUTING_CONFIG (archivo JSON) o, si
+// no está definida, usa ROUTING_MODE con los valores por defecto del modo.
+func loadRoutingConfig() (routingConfig, error) {
+	var cfg routingConfig
+	if path := getEnv("ROUTING_CONFIG", ""); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, err
+		}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("parsing %s: %w", path, err)
+		}
+		return cfg, nil
+	}
+	cfg.Mode = getEnv("ROUTING_MODE", routeBroadcast)
+	return cfg, nil
+}
+
+// setPolicy valida y activa una nueva política de enrutamiento.
+func (f *fanout) setPolicy(cfg routingConfig) error {
+	policy, err := newRoutingPolicy(cfg)
+	if err != nil {
+		return err
+	}
+	for _, name := range policy.names() {
+		if f.lookup(name) == nil {
+			log.Printf("Routing policy references unknown writer %q (it may not be described yet)", name)
+		}
+	}
+	f.policy.Store(policy)
+	log.Printf("Routing policy set: %+v", cfg)
+	return nil
+}
+
+// names devuelve todos los writers que menciona la política.
+func (p *routingPolicy) names() []string {
+	cfg := p.config
+	names := append([]string{}, cfg.Backends...)
+	if cfg.Primary != "" {
+		names = append(names, cfg.Primary)
+	}
+	names = append(names, cfg.Fallbacks...)
+	for name := range cfg.Weights {
+		names = append(names, name)
+	}
+	for _, rule := range cfg.Rules {
+		names = append(names, rule.Backends...)
+	}
+	return append(names, cfg.Default...)
+}
+
+// reloadRoutingOnSignal vuelve a leer la política al recibir SIGHUP.
+func reloadRoutingOnSignal(f *fanout) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	for range sighup {
+		cfg, err := loadRoutingConfig()
+		if err == nil {
+			err = f.setPolicy(cfg)
+		}
+		if err != nil {
+			log.Printf("Failed to reload routing policy: %v", err)
+		}
+	}
+}
+
+// handleRouting muestra (GET) o reemplaza (PUT) la política de enrutamiento
+// activa. PUT exige el header "Authorization: Bearer <token>" con
+// ROUTING_ADMIN_TOKEN; si no está definido, la política sólo cambia con
+// ROUTING_CONFIG y SIGHUP.
+func handleRouting(f *fanout, token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			if token == "" {
+				http.Error(w, "Routing updates are disabled: ROUTING_ADMIN_TOKEN is not set", http.StatusForbidden)
+				return
+			}
+			bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+				log.Printf("Rejected routing update from %s: invalid token", r.RemoteAddr)
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			var cfg routingConfig
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+			if err == nil {
+				err = json.Unmarshal(body, &cfg)
+			}
+			if err == nil {
+				err = f.setPolicy(cfg)
+			}
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Invalid routing policy: %v", err), http.StatusBadRequest)
+				return
+			}
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(f.policy.Load().config)
+	}
+}
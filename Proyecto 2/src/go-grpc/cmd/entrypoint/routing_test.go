@@ -0,0 +1,158 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"servidor-api-go/internal/proto"
+)
+
+func TestNewRoutingPolicyValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     routingConfig
+		wantErr bool
+	}{
+		{"broadcast", routingConfig{Mode: routeBroadcast}, false},
+		{"primary-fallback", routingConfig{Mode: routePrimaryFallback, Primary: "kafka"}, false},
+		{"primary-fallback without primary", routingConfig{Mode: routePrimaryFallback}, true},
+		{"round-robin", routingConfig{Mode: routeRoundRobin, Backends: []string{"kafka"}}, false},
+		{"round-robin without backends", routingConfig{Mode: routeRoundRobin}, true},
+		{"weighted", routingConfig{Mode: routeWeighted, Weights: map[string]int{"kafka": 1, "rabbitmq": 0}}, false},
+		{"weighted without weights", routingConfig{Mode: routeWeighted, Weights: map[string]int{"kafka": 0}}, true},
+		{"weighted with a negative weight", routingConfig{Mode: routeWeighted, Weights: map[string]int{"kafka": 2, "rabbitmq": -1}}, true},
+		{"rules", routingConfig{Mode: routeRules, Rules: []routingRule{{Field: "weather", Values: []string{"soleado"}, Backends: []string{"kafka"}}}, Default: []string{"rabbitmq"}}, false},
+		{"rules with only a default", routingConfig{Mode: routeRules, Default: []string{"kafka"}}, false},
+		{"rule on an unknown field", routingConfig{Mode: routeRules, Rules: []routingRule{{Field: "id", Values: []string{"1"}, Backends: []string{"kafka"}}}, Default: []string{"kafka"}}, true},
+		{"rule without values", routingConfig{Mode: routeRules, Rules: []routingRule{{Field: "country", Backends: []string{"kafka"}}}, Default: []string{"kafka"}}, true},
+		{"rule without backends", routingConfig{Mode: routeRules, Rules: []routingRule{{Field: "country", Values: []string{"GT"}}}, Default: []string{"kafka"}}, true},
+		{"rules without default", routingConfig{Mode: routeRules, Rules: []routingRule{{Field: "country", Values: []string{"GT"}, Backends: []string{"kafka"}}}}, true},
+		{"unknown mode", routingConfig{Mode: "random"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newRoutingPolicy(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newRoutingPolicy() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRoutingPolicyRoute(t *testing.T) {
+	rules := routingConfig{
+		Mode: routeRules,
+		Rules: []routingRule{
+			{Field: "country", Values: []string{"GT"}, Backends: []string{"kafka"}},
+			{Field: "weather", Values: []string{"Lluvioso"}, Backends: []string{"rabbitmq"}},
+		},
+		Default: []string{"kafka", "rabbitmq"},
+	}
+	tests := []struct {
+		name    string
+		cfg     routingConfig
+		reports []*proto.WeatherRequest
+		want    []routePlan
+	}{
+		{
+			name:    "broadcast goes to every writer",
+			cfg:     routingConfig{Mode: routeBroadcast},
+			reports: []*proto.WeatherRequest{{}},
+			want:    []routePlan{{}},
+		},
+		{
+			name:    "primary with fallbacks",
+			cfg:     routingConfig{Mode: routePrimaryFallback, Primary: "kafka", Fallbacks: []string{"rabbitmq"}},
+			reports: []*proto.WeatherRequest{{}},
+			want:    []routePlan{{targets: []string{"kafka"}, fallbacks: []string{"rabbitmq"}}},
+		},
+		{
+			name:    "round-robin rotates",
+			cfg:     routingConfig{Mode: routeRoundRobin, Backends: []string{"kafka", "rabbitmq"}},
+			reports: []*proto.WeatherRequest{{}, {}, {}},
+			want: []routePlan{
+				{targets: []string{"kafka"}},
+				{targets: []string{"rabbitmq"}},
+				{targets: []string{"kafka"}},
+			},
+		},
+		{
+			name:    "weighted with a single positive weight",
+			cfg:     routingConfig{Mode: routeWeighted, Weights: map[string]int{"kafka": 0, "rabbitmq": 3}},
+			reports: []*proto.WeatherRequest{{}, {}},
+			want:    []routePlan{{targets: []string{"rabbitmq"}}, {targets: []string{"rabbitmq"}}},
+		},
+		{
+			name: "rules match in order, ignoring case",
+			cfg:  rules,
+			reports: []*proto.WeatherRequest{
+				{Country: "gt", WeatherType: proto.Weather_WEATHER_LLUVIOSO},
+				{Country: "SV", WeatherType: proto.Weather_WEATHER_LLUVIOSO},
+				{Country: "SV", WeatherType: proto.Weather_WEATHER_SOLEADO},
+			},
+			want: []routePlan{
+				{targets: []string{"kafka"}},
+				{targets: []string{"rabbitmq"}},
+				{targets: []string{"kafka", "rabbitmq"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := newRoutingPolicy(tt.cfg)
+			if err != nil {
+				t.Fatalf("newRoutingPolicy: %v", err)
+			}
+			for i, report := range tt.reports {
+				if got := policy.route(report); !reflect.DeepEqual(got, tt.want[i]) {
+					t.Errorf("report %d: route = %+v, want %+v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestHandleRouting(t *testing.T) {
+	const update = `{"mode":"round-robin","backends":["kafka"]}`
+	tests := []struct {
+		name          string
+		token         string // ROUTING_ADMIN_TOKEN
+		method        string
+		authorization string
+		body          string
+
+		wantStatus int
+		wantMode   string // política activa después del pedido
+	}{
+		{name: "get", token: "secret", method: http.MethodGet, wantStatus: http.StatusOK, wantMode: routeBroadcast},
+		{name: "get without a token configured", method: http.MethodGet, wantStatus: http.StatusOK, wantMode: routeBroadcast},
+		{name: "put", token: "secret", method: http.MethodPut, authorization: "Bearer secret", body: update, wantStatus: http.StatusOK, wantMode: routeRoundRobin},
+		{name: "put disabled without a token configured", method: http.MethodPut, authorization: "Bearer ", body: update, wantStatus: http.StatusForbidden, wantMode: routeBroadcast},
+		{name: "put without authorization", token: "secret", method: http.MethodPut, body: update, wantStatus: http.StatusUnauthorized, wantMode: routeBroadcast},
+		{name: "put with a wrong token", token: "secret", method: http.MethodPut, authorization: "Bearer guess", body: update, wantStatus: http.StatusUnauthorized, wantMode: routeBroadcast},
+		{name: "put with another scheme", token: "secret", method: http.MethodPut, authorization: "Basic secret", body: update, wantStatus: http.StatusUnauthorized, wantMode: routeBroadcast},
+		{name: "put an invalid policy", token: "secret", method: http.MethodPut, authorization: "Bearer secret", body: `{"mode":"rules"}`, wantStatus: http.StatusBadRequest, wantMode: routeBroadcast},
+		{name: "post", token: "secret", method: http.MethodPost, authorization: "Bearer secret", body: update, wantStatus: http.StatusMethodNotAllowed, wantMode: routeBroadcast},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestFanout(t, nil)
+			r := httptest.NewRequest(tt.method, "/routing", strings.NewReader(tt.body))
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			handleRouting(f, tt.token)(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%s)", w.Code, tt.wantStatus, w.Body)
+			}
+			if mode := f.policy.Load().config.Mode; mode != tt.wantMode {
+				t.Errorf("active mode = %q, want %q", mode, tt.wantMode)
+			}
+		})
+	}
+}
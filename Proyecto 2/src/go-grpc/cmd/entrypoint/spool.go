@@ -0,0 +1,608 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"servidor-api-go/internal/proto"
+)
+
+const (
+	// spoolSegmentBytes es el tamaño a partir del cual se abre un segmento nuevo.
+	spoolSegmentBytes = 4 << 20
+	// spoolReplayBatch es la cantidad de registros que se leen por vuelta de reenvío.
+	spoolReplayBatch = 100
+	spoolCursorFile  = "cursor"
+	// spoolDeadFile guarda los registros que los writers rechazaron maxAttempts veces.
+	spoolDeadFile = "dead.ndjson"
+)
+
+var (
+	errSpoolFull = errors.New("spool is full")
+	// errSpoolRejected indica que los writers recibieron el registro y lo rechazaron.
+	errSpoolRejected = errors.New("spool record rejected by writers")
+	// errSpoolUndecodable indica que el reporte de un registro no se puede decodificar.
+	errSpoolUndecodable = errors.New("spool record cannot be decoded")
+)
+
+// Cómo sale un registro del spool al avanzar el cursor; cada uno se cuenta
+// en su propia estadística.
+const (
+	spoolReplayed = iota // publicado en sus writers
+	spoolExpired         // más viejo que maxAge
+	spoolBuried          // movido a dead.ndjson tras maxAttempts rechazos
+	spoolCorrupt         // línea o reporte ilegible, movido a dead.ndjson si se pudo leer
+)
+
+// spoolRecord es una línea de un segmento: un reporte aceptado que todavía no
+// llegó a todos sus writers.
+type spoolRecord struct {
+	SpooledAt time.Time `json:"spooled_at"`
+	// Targets son los writers que faltan; vacío significa volver a enrutar.
+	Targets []string        `json:"targets,omitempty"`
+	Request json.RawMessage `json:"request"` // WeatherRequest en protojson
+}
+
+// spoolSegment es un archivo NDJSON del spool; size es lo escrito hasta ahora.
+type spoolSegment struct {
+	seq  uint64
+	size int64
+}
+
+// spool es un buffer en disco, de sólo anexado, para los reportes que no se
+// pudieron publicar. Los registros se guardan en segmentos NDJSON y un
+// proceso en segundo plano los reenvía en orden cuando los writers se
+// recuperan. El cursor de lectura se persiste, así un reinicio retoma donde
+// quedó (un registro puede reenviarse más de una vez, nunca perderse).
+//
+// Un registro que los writers rechazan maxAttempts veces seguidas se mueve a
+// dead.ndjson para que no frene a los siguientes; los fallos pasajeros (un
+// writer caído) no cuentan como intentos. Un registro que sigue pendiente
+// para algunos writers se vuelve a anexar con su SpooledAt original, así
+// maxAge también lo alcanza.
+type spool struct {
+	dir         string
+	maxBytes    int64
+	maxAge      time.Duration
+	maxAttempts int
+	sync        bool
+	interval    time.Duration // cada cuánto reintentar el reenvío
+
+	mu       sync.Mutex
+	segments []spoolSegment // en orden; el último es el que recibe escrituras
+	active   *os.File
+	readSeq  uint64 // segmento y offset del próximo registro a reenviar
+	readOff  int64
+	depth    int
+	replayed int
+	expired  int
+	dead     int
+	corrupt  int
+	attempts int // rechazos seguidos del registro en el cursor
+
+	wake chan struct{}
+}
+
+// newSpoolFromEnv abre el spool según SPOOL_DIR, SPOOL_MAX_BYTES, SPOOL_MAX_AGE,
+// SPOOL_MAX_ATTEMPTS, SPOOL_SYNC y SPOOL_REPLAY_INTERVAL. Con SPOOL_DIR vacío
+// el spool queda deshabilitado (nil).
+func newSpoolFromEnv() *spool {
+	dir := getEnv("SPOOL_DIR", "/var/spool/entrypoint")
+	if dir == "" {
+		log.Printf("Spool disabled")
+		return nil
+	}
+	maxBytes, err := strconv.ParseInt(getEnv("SPOOL_MAX_BYTES", strconv.Itoa(256<<20)), 10, 64)
+	if err != nil || maxBytes < 1 {
+		log.Fatalf("Invalid SPOOL_MAX_BYTES: %q", getEnv("SPOOL_MAX_BYTES", ""))
+	}
+	maxAge, err := time.ParseDuration(getEnv("SPOOL_MAX_AGE", "24h"))
+	if err != nil {
+		log.Fatalf("Invalid SPOOL_MAX_AGE: %v", err)
+	}
+	maxAttempts, err := strconv.Atoi(getEnv("SPOOL_MAX_ATTEMPTS", "5"))
+	if err != nil || maxAttempts < 1 {
+		log.Fatalf("Invalid SPOOL_MAX_ATTEMPTS: %q", getEnv("SPOOL_MAX_ATTEMPTS", ""))
+	}
+	syncWrites, err := strconv.ParseBool(getEnv("SPOOL_SYNC", "true"))
+	if err != nil {
+		log.Fatalf("Invalid SPOOL_SYNC: %v", err)
+	}
+	interval, err := time.ParseDuration(getEnv("SPOOL_REPLAY_INTERVAL", "5s"))
+	if err != nil || interval <= 0 {
+		log.Fatalf("Invalid SPOOL_REPLAY_INTERVAL: %q", getEnv("SPOOL_REPLAY_INTERVAL", ""))
+	}
+
+	s, err := openSpool(dir, maxBytes, maxAge, syncWrites)
+	if err != nil {
+		log.Fatalf("Failed to open spool at %s: %v", dir, err)
+	}
+	s.interval = interval
+	s.maxAttempts = maxAttempts
+	log.Printf("Spool at %s: %d pending records (max %d bytes, max age %v)", dir, s.depth, maxBytes, maxAge)
+	return s
+}
+
+func openSpool(dir string, maxBytes int64, maxAge time.Duration, syncWrites bool) (*spool, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &spool{
+		dir:         dir,
+		maxBytes:    maxBytes,
+		maxAge:      maxAge,
+		maxAttempts: 1,
+		sync:        syncWrites,
+		wake:        make(chan struct{}, 1),
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		seq, ok := parseSegmentName(entry.Name())
+		if !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		s.segments = append(s.segments, spoolSegment{seq: seq, size: info.Size()})
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i].seq < s.segments[j].seq })
+
+	s.readSeq, s.readOff = s.loadCursor()
+	for len(s.segments) > 0 && s.segments[0].seq < s.readSeq {
+		os.Remove(s.segmentPath(s.segments[0].seq))
+		s.segments = s.segments[1:]
+	}
+	if len(s.segments) > 0 && s.segments[0].seq > s.readSeq {
+		s.readSeq, s.readOff = s.segments[0].seq, 0
+	}
+
+	// Contar lo pendiente; una línea cortada por una caída se ignora
+	for _, seg := range s.segments {
+		off := int64(0)
+		if seg.seq == s.readSeq {
+			off = s.readOff
+		}
+		records, err := s.readSegment(seg, off, -1)
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range records {
+			if rec.valid {
+				s.depth++
+			}
+		}
+	}
+
+	// Siempre se escribe en un segmento nuevo para no anexar tras una línea cortada
+	next := uint64(1)
+	if len(s.segments) > 0 {
+		next = s.segments[len(s.segments)-1].seq + 1
+	}
+	if len(s.segments) == 0 {
+		s.readSeq, s.readOff = next, 0
+	}
+	if err := s.openSegment(next); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// enqueue guarda un reporte para reenviarlo a targets (o volver a enrutarlo si
+// targets está vacío); spooledAt es cuándo entró por primera vez al spool.
+func (s *spool) enqueue(tweet *proto.WeatherRequest, targets []string, spooledAt time.Time) error {
+	request, err := protojson.Marshal(tweet)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(spoolRecord{SpooledAt: spooledAt, Targets: targets, Request: request})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pendingBytes()+int64(len(line)) > s.maxBytes {
+		return errSpoolFull
+	}
+	if s.segments[len(s.segments)-1].size >= spoolSegmentBytes {
+		if err := s.openSegment(s.segments[len(s.segments)-1].seq + 1); err != nil {
+			return err
+		}
+	}
+	if _, err := s.active.Write(line); err != nil {
+		return err
+	}
+	if s.sync {
+		if err := s.active.Sync(); err != nil {
+			return err
+		}
+	}
+	s.segments[len(s.segments)-1].size += int64(len(line))
+	s.depth++
+
+	s.notify()
+	return nil
+}
+
+// notify despierta al proceso de reenvío sin bloquear.
+func (s *spool) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// openSegment cierra el segmento activo y crea uno nuevo. Requiere s.mu.
+func (s *spool) openSegment(seq uint64) error {
+	file, err := os.OpenFile(s.segmentPath(seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if s.active != nil {
+		s.active.Close()
+	}
+	s.active = file
+	s.segments = append(s.segments, spoolSegment{seq: seq})
+	return nil
+}
+
+// pendingBytes es lo que ocupa en disco lo que falta reenviar. Requiere s.mu.
+func (s *spool) pendingBytes() int64 {
+	var total int64
+	for _, seg := range s.segments {
+		total += seg.size
+		if seg.seq == s.readSeq {
+			total -= s.readOff
+		}
+	}
+	return total
+}
+
+// spooledLine es una línea leída de un segmento junto con el offset donde termina.
+type spooledLine struct {
+	record spoolRecord
+	valid  bool
+	end    int64
+}
+
+// readSegment lee hasta max líneas completas (todas si max < 0) desde off.
+func (s *spool) readSegment(seg spoolSegment, off int64, max int) ([]spooledLine, error) {
+	file, err := os.Open(s.segmentPath(seg.seq))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if off > seg.size {
+		off = seg.size
+	}
+	var lines []spooledLine
+	reader := bufio.NewReader(io.NewSectionReader(file, off, seg.size-off))
+	for max < 0 || len(lines) < max {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break // sin salto de línea final: escritura interrumpida
+		}
+		if err != nil {
+			return nil, err
+		}
+		off += int64(len(data))
+		line := spooledLine{end: off}
+		line.valid = json.Unmarshal(data, &line.record) == nil
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// next devuelve el próximo bloque de registros a reenviar, borrando los
+// segmentos que ya se reenviaron por completo.
+func (s *spool) next() ([]spooledLine, error) {
+	for {
+		s.mu.Lock()
+		if len(s.segments) == 0 {
+			s.mu.Unlock()
+			return nil, nil
+		}
+		seg, off := s.segments[0], s.readOff
+		last := len(s.segments) == 1
+		s.mu.Unlock()
+
+		lines, err := s.readSegment(seg, off, spoolReplayBatch)
+		if err != nil || len(lines) > 0 || last {
+			return lines, err
+		}
+
+		// Segmento agotado y ya no es el activo: borrarlo y seguir con el siguiente
+		s.mu.Lock()
+		os.Remove(s.segmentPath(seg.seq))
+		s.segments = s.segments[1:]
+		s.readSeq, s.readOff = s.segments[0].seq, 0
+		s.mu.Unlock()
+		s.saveCursor()
+	}
+}
+
+// advance mueve el cursor después de line y la cuenta según cómo salió
+// del spool (spoolReplayed, spoolExpired, spoolBuried o spoolCorrupt).
+func (s *spool) advance(line spooledLine, how int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.readOff = line.end
+	s.attempts = 0
+	if line.valid {
+		s.depth-- // las líneas ilegibles no se contaron en depth
+	}
+	switch how {
+	case spoolReplayed:
+		s.replayed++
+	case spoolExpired:
+		s.expired++
+	case spoolBuried:
+		s.dead++
+	case spoolCorrupt:
+		s.corrupt++
+	}
+}
+
+// replay reenvía el spool a los writers cada vez que se agrega un registro y
+// cada s.interval, hasta que ctx se cancele.
+func (s *spool) replay(ctx context.Context, f *fanout) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-ticker.C:
+		}
+		if err := s.drain(ctx, f); err != nil {
+			log.Printf("Spool replay stopped: %v", err)
+		}
+	}
+}
+
+// drain reenvía registros en orden hasta vaciar el spool o hasta el primer
+// registro que no se pueda publicar.
+func (s *spool) drain(ctx context.Context, f *fanout) error {
+	for {
+		lines, err := s.next()
+		if err != nil || len(lines) == 0 {
+			return err
+		}
+
+		for _, line := range lines {
+			if !line.valid {
+				log.Printf("Skipping corrupt spool record")
+				s.advance(line, spoolCorrupt)
+				continue
+			}
+			if time.Since(line.record.SpooledAt) > s.maxAge {
+				log.Printf("Dropping spool record older than %v", s.maxAge)
+				s.advance(line, spoolExpired)
+				continue
+			}
+
+			err := s.resend(ctx, f, line.record)
+			how := spoolReplayed
+			switch {
+			case errors.Is(err, errSpoolUndecodable):
+				log.Printf("Moving spool record to %s: %v", spoolDeadFile, err)
+				how = spoolCorrupt
+			case errors.Is(err, errSpoolRejected) && s.reject():
+				log.Printf("Moving spool record to %s after %d rejections: %v", spoolDeadFile, s.maxAttempts, err)
+				how = spoolBuried
+			case err != nil:
+				s.saveCursor()
+				return err
+			}
+			if how != spoolReplayed {
+				if err := s.bury(line.record); err != nil {
+					s.saveCursor()
+					return err
+				}
+			}
+			s.advance(line, how)
+		}
+		s.saveCursor()
+	}
+}
+
+// reject cuenta un rechazo del registro en el cursor e indica si llegó a
+// maxAttempts.
+func (s *spool) reject() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts++
+	return s.attempts >= s.maxAttempts
+}
+
+// bury anexa rec a dead.ndjson, fuera de los segmentos que se reenvían.
+func (s *spool) bury(rec spoolRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(s.dir, spoolDeadFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if s.sync {
+		return file.Sync()
+	}
+	return nil
+}
+
+// resend publica un registro del spool. Si sólo algunos writers fallan, el
+// registro se vuelve a anexar con esos writers como destino y se da por
+// reenviado; si no avanzó nada devuelve el error para reintentar más tarde,
+// envuelto en errSpoolRejected si ningún fallo fue pasajero. Un reporte que no
+// se puede decodificar devuelve errSpoolUndecodable.
+func (s *spool) resend(ctx context.Context, f *fanout, rec spoolRecord) error {
+	tweet, err := decodeSpooledRequest(rec.Request)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errSpoolUndecodable, err)
+	}
+
+	plan := routePlan{targets: rec.Targets}
+	if len(rec.Targets) == 0 || len(f.resolve(rec.Targets)) == 0 {
+		plan = f.policy.Load().route(tweet)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	outcome := f.publishPlan(ctx, tweet, plan)
+	if outcome.complete() {
+		return nil
+	}
+	pending := outcome.pending()
+	if len(pending) == 0 || len(pending) >= len(outcome.targets) {
+		if outcome.rejected() {
+			return fmt.Errorf("%w: %v", errSpoolRejected, outcome.err())
+		}
+		return outcome.err()
+	}
+	log.Printf("Spool record %s still pending for %v", tweet.GetId(), pending)
+	if err := s.enqueue(tweet, pending, rec.SpooledAt); err != nil {
+		return fmt.Errorf("re-spooling %s: %w", tweet.GetId(), err)
+	}
+	return nil
+}
+
+// decodeSpooledRequest decodifica el reporte de un registro. Los registros
+// escritos antes de weather_type guardan el clima como texto en "weather".
+func decodeSpooledRequest(data []byte) (*proto.WeatherRequest, error) {
+	tweet := &proto.WeatherRequest{}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, tweet); err != nil {
+		return nil, err
+	}
+	upgradeLegacyWeather(tweet)
+	return tweet, nil
+}
+
+// spoolIncomplete guarda en el spool las copias que le faltan a un reporte
+// para reenviarlas más tarde. spooled es false si el reporte ya estaba
+// completo o el spool está deshabilitado.
+func (f *fanout) spoolIncomplete(tweet *proto.WeatherRequest, outcome *publishOutcome) (spooled bool, err error) {
+	if outcome.complete() || f.spool == nil {
+		return false, nil
+	}
+	if err := f.spool.enqueue(tweet, outcome.pending(), time.Now()); err != nil {
+		log.Printf("Failed to spool tweet %s: %v", tweet.GetId(), err)
+		return false, err
+	}
+	log.Printf("Spooled tweet %s after publish error: %v", tweet.GetId(), outcome.err())
+	return true, nil
+}
+
+func (s *spool) segmentPath(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("segment-%016d.ndjson", seq))
+}
+
+func parseSegmentName(name string) (uint64, bool) {
+	if !strings.HasPrefix(name, "segment-") || !strings.HasSuffix(name, ".ndjson") {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, "segment-"), ".ndjson"), 10, 64)
+	return seq, err == nil
+}
+
+// loadCursor lee "<segmento> <offset>" del archivo de cursor.
+func (s *spool) loadCursor() (uint64, int64) {
+	data, err := os.ReadFile(filepath.Join(s.dir, spoolCursorFile))
+	if err != nil {
+		return 0, 0
+	}
+	var seq uint64
+	var off int64
+	if _, err := fmt.Sscanf(string(data), "%d %d", &seq, &off); err != nil {
+		log.Printf("Ignoring invalid spool cursor: %v", err)
+		return 0, 0
+	}
+	return seq, off
+}
+
+// saveCursor persiste la posición de lectura reemplazando el archivo de forma atómica.
+func (s *spool) saveCursor() {
+	s.mu.Lock()
+	data := fmt.Sprintf("%d %d\n", s.readSeq, s.readOff)
+	s.mu.Unlock()
+
+	path := filepath.Join(s.dir, spoolCursorFile)
+	if err := os.WriteFile(path+".tmp", []byte(data), 0o644); err != nil {
+		log.Printf("Failed to save spool cursor: %v", err)
+		return
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		log.Printf("Failed to save spool cursor: %v", err)
+	}
+}
+
+// spoolStats es el estado del spool que se expone en /spool.
+type spoolStats struct {
+	Enabled  bool   `json:"enabled"`
+	Depth    int    `json:"depth"`
+	Bytes    int64  `json:"bytes"`
+	MaxBytes int64  `json:"max_bytes"`
+	Segments int    `json:"segments"`
+	Replayed int    `json:"replayed"`
+	Expired  int    `json:"expired"`
+	Dead     int    `json:"dead"`
+	Corrupt  int    `json:"corrupt"`
+	Dir      string `json:"dir,omitempty"`
+}
+
+func (s *spool) stats() spoolStats {
+	if s == nil {
+		return spoolStats{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return spoolStats{
+		Enabled:  true,
+		Depth:    s.depth,
+		Bytes:    s.pendingBytes(),
+		MaxBytes: s.maxBytes,
+		Segments: len(s.segments),
+		Replayed: s.replayed,
+		Expired:  s.expired,
+		Dead:     s.dead,
+		Corrupt:  s.corrupt,
+		Dir:      s.dir,
+	}
+}
+
+// handleSpool muestra la profundidad y el estado del spool.
+func handleSpool(s *spool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.stats())
+	}
+}
@@ -0,0 +1,475 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"servidor-api-go/internal/proto"
+)
+
+func openTestSpool(t *testing.T, dir string, maxBytes int64) *spool {
+	t.Helper()
+	s, err := openSpool(dir, maxBytes, time.Hour, false)
+	if err != nil {
+		t.Fatalf("openSpool: %v", err)
+	}
+	t.Cleanup(func() { s.active.Close() })
+	return s
+}
+
+func enqueueTestReports(t *testing.T, s *spool, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		if err := s.enqueue(&proto.WeatherRequest{Id: id, Country: "GT"}, []string{"kafka"}, time.Now()); err != nil {
+			t.Fatalf("enqueue %s: %v", id, err)
+		}
+	}
+}
+
+// fakeWriter es un writer en memoria: acepta los primeros accept reportes
+// (todos si accept < 0) y después falla con failCode o, si está vacío, como
+// un writer caído.
+type fakeWriter struct {
+	proto.PublisherServiceClient
+
+	mu        sync.Mutex
+	accept    int
+	failCode  string
+	calls     int
+	published []string
+}
+
+func (w *fakeWriter) Publish(_ context.Context, in *proto.WeatherRequest, _ ...grpc.CallOption) (*proto.WeatherResponse, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.calls++
+	if w.accept == 0 {
+		if w.failCode != "" {
+			return &proto.WeatherResponse{Code: w.failCode, Message: "rejected"}, nil
+		}
+		return nil, status.Error(codes.Unavailable, "writer down")
+	}
+	if w.accept > 0 {
+		w.accept--
+	}
+	w.published = append(w.published, in.GetId())
+	return &proto.WeatherResponse{Success: true, Id: in.GetId()}, nil
+}
+
+// PublishBatch publica cada reporte como Publish; con el writer caído falla
+// la llamada entera.
+func (w *fakeWriter) PublishBatch(ctx context.Context, in *proto.WeatherBatchRequest, _ ...grpc.CallOption) (*proto.WeatherBatchResponse, error) {
+	resp := &proto.WeatherBatchResponse{}
+	for i, req := range in.GetRequests() {
+		result, err := w.Publish(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		resp.Results = append(resp.Results, &proto.WeatherResult{Index: int32(i), Id: req.GetId(), Success: result.Success, Message: result.Message, Code: result.Code})
+		if result.Success {
+			resp.Published++
+		} else {
+			resp.Failed++
+		}
+	}
+	return resp, nil
+}
+
+func newTestBackend(name string, w *fakeWriter) *backend {
+	return &backend{
+		addr:      name,
+		name:      name,
+		described: true,
+		client:    w,
+		policy:    callPolicy{attempts: 1, initialBackoff: time.Millisecond, maxBackoff: time.Millisecond, timeout: time.Second},
+		breaker:   &circuitBreaker{state: breakerClosed, threshold: 1000, cooldown: time.Hour},
+	}
+}
+
+// newTestFanout publica en backends con broadcast y quorum "all".
+func newTestFanout(t *testing.T, s *spool, backends ...*backend) *fanout {
+	t.Helper()
+	f := &fanout{backends: backends, spool: s, quorum: writeQuorum{mode: "all"}}
+	policy, err := newRoutingPolicy(routingConfig{Mode: routeBroadcast})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.policy.Store(policy)
+	return f
+}
+
+// replayTestReports reenvía con drain hasta limit registros (todos si
+// limit < 0) y devuelve sus ids.
+func replayTestReports(t *testing.T, s *spool, limit int) []string {
+	t.Helper()
+	w := &fakeWriter{accept: limit}
+	s.drain(context.Background(), newTestFanout(t, s, newTestBackend("kafka", w)))
+	return w.published
+}
+
+func TestSpoolReplayResumesFromCursor(t *testing.T) {
+	tests := []struct {
+		name      string
+		before    []string // encolados antes del primer reinicio
+		replayed  int      // reenviados antes del reinicio
+		after     []string // encolados después del reinicio
+		wantFirst []string
+		wantRest  []string
+	}{
+		{
+			name:     "empty spool",
+			wantRest: nil,
+		},
+		{
+			name:     "nothing replayed",
+			before:   []string{"a", "b", "c"},
+			wantRest: []string{"a", "b", "c"},
+		},
+		{
+			name:      "partially replayed",
+			before:    []string{"a", "b", "c"},
+			replayed:  2,
+			wantFirst: []string{"a", "b"},
+			wantRest:  []string{"c"},
+		},
+		{
+			name:      "fully replayed",
+			before:    []string{"a", "b"},
+			replayed:  2,
+			wantFirst: []string{"a", "b"},
+			wantRest:  nil,
+		},
+		{
+			name:      "new segment after restart",
+			before:    []string{"a", "b"},
+			replayed:  1,
+			after:     []string{"c", "d"},
+			wantFirst: []string{"a"},
+			wantRest:  []string{"b", "c", "d"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s := openTestSpool(t, dir, 1<<20)
+			enqueueTestReports(t, s, tt.before...)
+			if got := replayTestReports(t, s, tt.replayed); !reflect.DeepEqual(got, tt.wantFirst) {
+				t.Fatalf("replayed before restart = %v, want %v", got, tt.wantFirst)
+			}
+
+			s = openTestSpool(t, dir, 1<<20)
+			enqueueTestReports(t, s, tt.after...)
+			if depth := s.stats().Depth; depth != len(tt.wantRest) {
+				t.Errorf("depth after restart = %d, want %d", depth, len(tt.wantRest))
+			}
+			if got := replayTestReports(t, s, -1); !reflect.DeepEqual(got, tt.wantRest) {
+				t.Errorf("replayed after restart = %v, want %v", got, tt.wantRest)
+			}
+
+			stats := s.stats()
+			if stats.Depth != 0 || stats.Bytes != 0 {
+				t.Errorf("after replay: depth %d, bytes %d, want 0", stats.Depth, stats.Bytes)
+			}
+			if stats.Segments != 1 {
+				t.Errorf("after replay: %d segments, want only the active one", stats.Segments)
+			}
+		})
+	}
+}
+
+func TestSpoolIgnoresTruncatedRecord(t *testing.T) {
+	dir := t.TempDir()
+	s := openTestSpool(t, dir, 1<<20)
+	enqueueTestReports(t, s, "a")
+
+	// Una caída a mitad de escritura deja una línea sin salto final
+	file, err := os.OpenFile(s.segmentPath(s.readSeq), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(file, `{"spooled_at":"2025-05-01T00:00:00Z","request":{"id":"b"`)
+	file.Close()
+
+	s = openTestSpool(t, dir, 1<<20)
+	enqueueTestReports(t, s, "c")
+	if depth := s.stats().Depth; depth != 2 {
+		t.Errorf("depth = %d, want 2", depth)
+	}
+	if got, want := replayTestReports(t, s, -1), []string{"a", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("replayed = %v, want %v", got, want)
+	}
+}
+
+func TestSpoolInvalidCursor(t *testing.T) {
+	dir := t.TempDir()
+	s := openTestSpool(t, dir, 1<<20)
+	enqueueTestReports(t, s, "a", "b")
+	replayTestReports(t, s, 1)
+
+	if err := os.WriteFile(filepath.Join(dir, spoolCursorFile), []byte("garbage"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s = openTestSpool(t, dir, 1<<20)
+	if got, want := replayTestReports(t, s, -1), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("replayed = %v, want %v", got, want)
+	}
+}
+
+func TestSpoolFull(t *testing.T) {
+	s := openTestSpool(t, t.TempDir(), 400)
+	var err error
+	n := 0
+	for ; n < 10 && err == nil; n++ {
+		err = s.enqueue(&proto.WeatherRequest{Id: fmt.Sprint(n), Country: "GT"}, nil, time.Now())
+	}
+	if !errors.Is(err, errSpoolFull) {
+		t.Fatalf("enqueue error = %v, want errSpoolFull", err)
+	}
+	if depth := s.stats().Depth; depth != n-1 {
+		t.Errorf("depth = %d, want %d", depth, n-1)
+	}
+
+	// Reenviar libera espacio
+	replayTestReports(t, s, -1)
+	if err := s.enqueue(&proto.WeatherRequest{Id: "again", Country: "GT"}, nil, time.Now()); err != nil {
+		t.Errorf("enqueue after replay: %v", err)
+	}
+}
+
+func TestSpoolDrain(t *testing.T) {
+	type record struct {
+		id      string
+		age     time.Duration // cuánto hace que entró al spool
+		request string        // reemplaza el reporte codificado
+	}
+	tests := []struct {
+		name        string
+		records     []record
+		maxAge      time.Duration
+		maxAttempts int
+		writers     map[string]*fakeWriter
+		drains      int
+
+		wantPublished map[string][]string
+		wantStats     spoolStats // sólo Depth, Replayed, Expired, Dead y Corrupt
+		wantBuried    int        // líneas en dead.ndjson
+	}{
+		{
+			name:          "replayed",
+			records:       []record{{id: "a"}, {id: "b"}},
+			writers:       map[string]*fakeWriter{"kafka": {accept: -1}},
+			wantPublished: map[string][]string{"kafka": {"a", "b"}},
+			wantStats:     spoolStats{Replayed: 2},
+		},
+		{
+			name:      "writer down keeps the records",
+			records:   []record{{id: "a"}, {id: "b"}},
+			writers:   map[string]*fakeWriter{"kafka": {}},
+			drains:    3,
+			wantStats: spoolStats{Depth: 2},
+		},
+		{
+			name:        "rejected record waits for maxAttempts",
+			records:     []record{{id: "a"}},
+			maxAttempts: 2,
+			writers:     map[string]*fakeWriter{"kafka": {failCode: "MARSHAL_FAILED"}},
+			wantStats:   spoolStats{Depth: 1},
+		},
+		{
+			name:        "rejected record is buried once",
+			records:     []record{{id: "a"}, {id: "b"}},
+			maxAttempts: 2,
+			writers:     map[string]*fakeWriter{"kafka": {failCode: "MARSHAL_FAILED"}},
+			drains:      4,
+			wantStats:   spoolStats{Dead: 2},
+			wantBuried:  2,
+		},
+		{
+			name:          "expired record is dropped",
+			records:       []record{{id: "a", age: 2 * time.Hour}, {id: "b"}},
+			maxAge:        time.Hour,
+			writers:       map[string]*fakeWriter{"kafka": {accept: -1}},
+			wantStats:     spoolStats{Replayed: 1, Expired: 1},
+			wantPublished: map[string][]string{"kafka": {"b"}},
+		},
+		{
+			name:          "undecodable record is buried as corrupt",
+			records:       []record{{id: "a", request: `{"id":5}`}, {id: "b"}},
+			writers:       map[string]*fakeWriter{"kafka": {accept: -1}},
+			wantPublished: map[string][]string{"kafka": {"b"}},
+			wantStats:     spoolStats{Replayed: 1, Corrupt: 1},
+			wantBuried:    1,
+		},
+		{
+			name:          "partially published record stays for the missing writer",
+			records:       []record{{id: "a"}},
+			writers:       map[string]*fakeWriter{"kafka": {accept: -1}, "rabbitmq": {}},
+			wantPublished: map[string][]string{"kafka": {"a"}},
+			wantStats:     spoolStats{Depth: 1, Replayed: 1},
+		},
+		{
+			name:          "re-spooled record keeps its age",
+			records:       []record{{id: "a", age: 50 * time.Minute}},
+			maxAge:        time.Hour,
+			writers:       map[string]*fakeWriter{"kafka": {accept: -1}, "rabbitmq": {}},
+			drains:        2,
+			wantPublished: map[string][]string{"kafka": {"a"}},
+			wantStats:     spoolStats{Depth: 1, Replayed: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s := openTestSpool(t, dir, 1<<20)
+			s.maxAge = time.Hour
+			if tt.maxAge > 0 {
+				s.maxAge = tt.maxAge
+			}
+			if tt.maxAttempts > 0 {
+				s.maxAttempts = tt.maxAttempts
+			}
+
+			spooledAt := make(map[string]time.Time)
+			for _, rec := range tt.records {
+				at := time.Now().Add(-rec.age).UTC().Round(time.Millisecond)
+				spooledAt[rec.id] = at
+				if err := s.enqueue(&proto.WeatherRequest{Id: rec.id, Country: "GT"}, nil, at); err != nil {
+					t.Fatalf("enqueue %s: %v", rec.id, err)
+				}
+				if rec.request != "" {
+					// Reemplazar el reporte del último registro escrito
+					corruptLastRecord(t, s, rec.request)
+				}
+			}
+
+			var backends []*backend
+			for _, name := range []string{"kafka", "rabbitmq"} {
+				if w, ok := tt.writers[name]; ok {
+					backends = append(backends, newTestBackend(name, w))
+				}
+			}
+			f := newTestFanout(t, s, backends...)
+			for i := 0; i < max(tt.drains, 1); i++ {
+				s.drain(context.Background(), f)
+			}
+
+			for name, w := range tt.writers {
+				if !reflect.DeepEqual(w.published, tt.wantPublished[name]) {
+					t.Errorf("%s published %v, want %v", name, w.published, tt.wantPublished[name])
+				}
+			}
+			stats := s.stats()
+			got := spoolStats{Depth: stats.Depth, Replayed: stats.Replayed, Expired: stats.Expired, Dead: stats.Dead, Corrupt: stats.Corrupt}
+			if got != tt.wantStats {
+				t.Errorf("stats = %+v, want %+v", got, tt.wantStats)
+			}
+			if buried := countLines(t, filepath.Join(dir, spoolDeadFile)); buried != tt.wantBuried {
+				t.Errorf("%s has %d records, want %d", spoolDeadFile, buried, tt.wantBuried)
+			}
+
+			// Lo que queda pendiente conserva el momento en que entró al spool
+			lines, err := s.next()
+			if err != nil {
+				t.Fatalf("next: %v", err)
+			}
+			for _, line := range lines {
+				tweet, err := decodeSpooledRequest(line.record.Request)
+				if err != nil {
+					t.Fatalf("decodeSpooledRequest: %v", err)
+				}
+				if want := spooledAt[tweet.GetId()]; !line.record.SpooledAt.Equal(want) {
+					t.Errorf("pending %s spooled at %v, want %v", tweet.GetId(), line.record.SpooledAt, want)
+				}
+			}
+		})
+	}
+}
+
+// corruptLastRecord reescribe el reporte del último registro del segmento activo.
+func corruptLastRecord(t *testing.T, s *spool, request string) {
+	t.Helper()
+	path := s.segmentPath(s.segments[len(s.segments)-1].seq)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	last := lines[len(lines)-2] // la última es vacía tras el salto final
+	var rec spoolRecord
+	if err := json.Unmarshal(last, &rec); err != nil {
+		t.Fatal(err)
+	}
+	rec.Request = json.RawMessage(request)
+	replaced, err := json.Marshal(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines[len(lines)-2] = append(replaced, '\n')
+	data = bytes.Join(lines, nil)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s.segments[len(s.segments)-1].size = int64(len(data))
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	n := 0
+	for scanner := bufio.NewScanner(file); scanner.Scan(); {
+		n++
+	}
+	return n
+}
+
+func TestDecodeSpooledRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    proto.Weather
+		wantErr bool
+	}{
+		{name: "weather_type", data: `{"id":"1","weatherType":"WEATHER_SOLEADO"}`, want: proto.Weather_WEATHER_SOLEADO},
+		{name: "legacy weather text", data: `{"id":"1","weather":"lluvioso"}`, want: proto.Weather_WEATHER_LLUVIOSO},
+		{name: "legacy text is normalized", data: `{"id":"1","weather":" Nubloso "}`, want: proto.Weather_WEATHER_NUBLOSO},
+		{name: "weather_type wins", data: `{"id":"1","weather":"lluvioso","weatherType":"WEATHER_SOLEADO"}`, want: proto.Weather_WEATHER_SOLEADO},
+		{name: "unknown legacy weather", data: `{"id":"1","weather":"granizo"}`},
+		{name: "unknown fields are ignored", data: `{"id":"1","weatherType":"WEATHER_SOLEADO","extra":true}`, want: proto.Weather_WEATHER_SOLEADO},
+		{name: "wrong type", data: `{"id":5}`, wantErr: true},
+		{name: "not JSON", data: `{"id":`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tweet, err := decodeSpooledRequest([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeSpooledRequest(%s) error = %v, want error %v", tt.data, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tweet.GetWeatherType() != tt.want || tweet.GetWeather() != "" {
+				t.Errorf("decodeSpooledRequest(%s) weather_type = %v, weather = %q; want %v and no legacy text", tt.data, tweet.GetWeatherType(), tweet.GetWeather(), tt.want)
+			}
+		})
+	}
+}
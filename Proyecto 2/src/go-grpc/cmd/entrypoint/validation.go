@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"servidor-api-go/internal/message"
+	"servidor-api-go/internal/proto"
+)
+
+// maxDescriptionLength es el largo máximo de la descripción, en caracteres.
+const maxDescriptionLength = 280
+
+// weatherNames lista los valores válidos de weather para los mensajes de error.
+func weatherNames() string {
+	return strings.Join(message.WeatherNames(), ", ")
+}
+
+// fieldError describe un problema con un campo del reporte.
+type fieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"` // "required", "invalid", "too_long", "unknown_field", "invalid_json"
+	Message string `json:"message"`
+}
+
+// validationError reúne los problemas de un reporte, campo por campo.
+type validationError struct {
+	Fields []fieldError `json:"fields"`
+}
+
+func (e *validationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		if f.Field == "" {
+			messages[i] = f.Message
+		} else {
+			messages[i] = f.Field + ": " + f.Message
+		}
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e *validationError) add(field, code, format string, args ...any) {
+	e.Fields = append(e.Fields, fieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// grpcStatus convierte el error en InvalidArgument con un BadRequest por campo.
+func (e *validationError) grpcStatus() error {
+	st := status.New(codes.InvalidArgument, e.Error())
+	violations := make([]*errdetails.BadRequest_FieldViolation, len(e.Fields))
+	for i, f := range e.Fields {
+		violations[i] = &errdetails.BadRequest_FieldViolation{Field: f.Field, Description: f.Message}
+	}
+	if detailed, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// validateRequest normaliza el reporte y comprueba sus campos: weather con un
+// valor conocido del enum, country como código ISO 3166-1 alfa-2 (se
+// aceptan alfa-3 y algunos alias como "EEUU") y una descripción no vacía de
+// hasta maxDescriptionLength caracteres. Devuelve *validationError.
+func validateRequest(tweet *proto.WeatherRequest) error {
+	errs := &validationError{}
+
+	tweet.Description = strings.TrimSpace(tweet.GetDescription())
+	switch length := utf8.RuneCountInString(tweet.Description); {
+	case length == 0:
+		errs.add("description", "required", "description is required")
+	case length > maxDescriptionLength:
+		errs.add("description", "too_long", "description has %d characters, the maximum is %d", length, maxDescriptionLength)
+	}
+
+	country := strings.ToUpper(strings.TrimSpace(tweet.GetCountry()))
+	if alpha2, ok := countryAlpha3[country]; ok {
+		country = alpha2
+	} else if alpha2, ok := countryAliases[country]; ok {
+		country = alpha2
+	}
+	switch {
+	case country == "":
+		errs.add("country", "required", "country is required")
+	case !countryAlpha2[country]:
+		errs.add("country", "invalid", "%q is not an ISO 3166-1 country code", tweet.GetCountry())
+	default:
+		tweet.Country = country
+	}
+
+	legacy := tweet.GetWeather()
+	switch weather := upgradeLegacyWeather(tweet); {
+	case weather == proto.Weather_WEATHER_UNSPECIFIED && legacy != "":
+		errs.add("weather", "invalid", "%q is not one of %s", legacy, weatherNames())
+	case weather == proto.Weather_WEATHER_UNSPECIFIED:
+		errs.add("weather", "required", "weather is required")
+	case proto.Weather_name[int32(weather)] == "":
+		errs.add("weather", "invalid", "%d is not one of %s", int32(weather), weatherNames())
+	}
+
+	if len(errs.Fields) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// upgradeLegacyWeather traduce a weather_type el clima como texto del campo
+// weather (3), que siguen enviando los clientes anteriores a weather_type, y
+// vacía ese campo. Devuelve weather_type, que queda sin fijar si el texto no
+// es un clima conocido.
+func upgradeLegacyWeather(tweet *proto.WeatherRequest) proto.Weather {
+	if legacy := tweet.GetWeather(); legacy != "" {
+		if tweet.GetWeatherType() == proto.Weather_WEATHER_UNSPECIFIED {
+			tweet.WeatherType, _ = message.ParseWeather(strings.ToLower(strings.TrimSpace(legacy)))
+		}
+		tweet.Weather = ""
+	}
+	return tweet.GetWeatherType()
+}
+
+// writeValidationError responde 400 con el detalle de cada campo.
+func writeValidationError(w http.ResponseWriter, errs *validationError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(struct {
+		Error  string       `json:"error"`
+		Fields []fieldError `json:"fields"`
+	}{Error: "invalid request", Fields: errs.Fields})
+}
+
+// decodeReport decodifica el JSON de un reporte campo por campo, así cada
+// problema se informa con el campo que lo causó. Acepta los nombres en
+// snake_case y en camelCase; ingested_at se ignora porque lo fija el
+// entrypoint. El reporte todavía debe pasar por validateRequest.
+func decodeReport(data []byte) (*proto.WeatherRequest, *validationError) {
+	errs := &validationError{}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		errs.add("", "invalid_json", "%v", err)
+		return nil, errs
+	}
+
+	tweet := &proto.WeatherRequest{}
+	for _, name := range slices.Sorted(maps.Keys(fields)) {
+		raw := fields[name]
+		switch name {
+		case "description":
+			decodeString(errs, name, raw, &tweet.Description)
+		case "country":
+			decodeString(errs, name, raw, &tweet.Country)
+		case "id":
+			decodeString(errs, name, raw, &tweet.Id)
+		case "source":
+			decodeString(errs, name, raw, &tweet.Source)
+		case "weather":
+			var weather string
+			if !decodeString(errs, name, raw, &weather) {
+				break
+			}
+			// Se aceptan mayúsculas y espacios, como " Soleado"
+			if normalized := strings.ToLower(strings.TrimSpace(weather)); normalized != "" {
+				value, ok := message.ParseWeather(normalized)
+				if !ok {
+					errs.add(name, "invalid", "%q is not one of %s", weather, weatherNames())
+				}
+				tweet.WeatherType = value
+			}
+		case "event_time", "eventTime":
+			tweet.EventTime = decodeTimestamp(errs, name, raw)
+		case "ingested_at", "ingestedAt":
+			decodeTimestamp(errs, name, raw)
+		default:
+			errs.add(name, "unknown_field", "unknown field")
+		}
+	}
+	if len(errs.Fields) > 0 {
+		return nil, errs
+	}
+	return tweet, nil
+}
+
+// decodeString decodifica un campo de texto; null lo deja vacío.
+func decodeString(errs *validationError, name string, raw json.RawMessage, dst *string) bool {
+	if err := json.Unmarshal(raw, dst); err != nil {
+		errs.add(name, "invalid", "%s must be a string", name)
+		return false
+	}
+	return true
+}
+
+// decodeTimestamp decodifica un campo de fecha en RFC 3339; null lo deja sin fijar.
+func decodeTimestamp(errs *validationError, name string, raw json.RawMessage) *timestamppb.Timestamp {
+	var value *string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		errs.add(name, "invalid", "%s must be an RFC 3339 timestamp string", name)
+		return nil
+	}
+	if value == nil {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, *value)
+	if err != nil {
+		errs.add(name, "invalid", "%q is not an RFC 3339 timestamp", *value)
+		return nil
+	}
+	return timestamppb.New(t)
+}
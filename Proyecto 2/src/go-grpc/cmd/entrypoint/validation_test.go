@@ -0,0 +1,261 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	protobuf "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"servidor-api-go/internal/proto"
+)
+
+func TestDecodeReport(t *testing.T) {
+	eventTime := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		data    string
+		want    *proto.WeatherRequest
+		wantErr string
+		// invalid_json sólo se compara por código: el mensaje es el del paquete json
+		wantInvalidJSON bool
+	}{
+		{
+			name: "snake_case fields",
+			data: `{"id":"a1","description":"Sol","country":"GT","weather":"soleado","event_time":"2024-05-01T12:30:00Z","source":"app"}`,
+			want: &proto.WeatherRequest{Id: "a1", Description: "Sol", Country: "GT", WeatherType: proto.Weather_WEATHER_SOLEADO, EventTime: timestamppb.New(eventTime), Source: "app"},
+		},
+		{
+			name: "camelCase event time",
+			data: `{"description":"Sol","country":"GT","weather":"soleado","eventTime":"2024-05-01T14:30:00+02:00"}`,
+			want: &proto.WeatherRequest{Description: "Sol", Country: "GT", WeatherType: proto.Weather_WEATHER_SOLEADO, EventTime: timestamppb.New(eventTime)},
+		},
+		{
+			name: "weather ignores case and spaces",
+			data: `{"weather":" LLUVIOSO "}`,
+			want: &proto.WeatherRequest{WeatherType: proto.Weather_WEATHER_LLUVIOSO},
+		},
+		{
+			name: "empty weather is left for validateRequest",
+			data: `{"weather":"  "}`,
+			want: &proto.WeatherRequest{},
+		},
+		{
+			name: "ingested_at is ignored",
+			data: `{"ingested_at":"2024-05-01T12:30:00Z","ingestedAt":null}`,
+			want: &proto.WeatherRequest{},
+		},
+		{
+			name: "null leaves fields unset",
+			data: `{"description":null,"event_time":null}`,
+			want: &proto.WeatherRequest{},
+		},
+		{
+			name:    "unknown weather",
+			data:    `{"weather":"Granizo"}`,
+			wantErr: `weather: "Granizo" is not one of lluvioso, nubloso, soleado`,
+		},
+		{
+			name:    "unknown fields",
+			data:    `{"weather_type":1,"clima":"soleado"}`,
+			wantErr: "clima: unknown field; weather_type: unknown field",
+		},
+		{
+			name:    "wrong types",
+			data:    `{"country":502,"description":["a"],"weather":true}`,
+			wantErr: "country: country must be a string; description: description must be a string; weather: weather must be a string",
+		},
+		{
+			name:    "timestamp that is not a string",
+			data:    `{"eventTime":1714566600}`,
+			wantErr: "eventTime: eventTime must be an RFC 3339 timestamp string",
+		},
+		{
+			name:    "timestamp that is not RFC 3339",
+			data:    `{"event_time":"01/05/2024"}`,
+			wantErr: `event_time: "01/05/2024" is not an RFC 3339 timestamp`,
+		},
+		{
+			name:            "not an object",
+			data:            `["soleado"]`,
+			wantInvalidJSON: true,
+		},
+		{
+			name:            "truncated JSON",
+			data:            `{"country":"GT"`,
+			wantInvalidJSON: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, errs := decodeReport([]byte(tt.data))
+			if tt.wantInvalidJSON {
+				if errs == nil || len(errs.Fields) != 1 || errs.Fields[0].Code != "invalid_json" || errs.Fields[0].Field != "" {
+					t.Fatalf("decodeReport(%s) error = %+v, want a single invalid_json error", tt.data, errs)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				if errs == nil || errs.Error() != tt.wantErr {
+					t.Fatalf("decodeReport(%s) error = %v, want %q", tt.data, errs, tt.wantErr)
+				}
+				return
+			}
+			if errs != nil {
+				t.Fatalf("decodeReport(%s): %v", tt.data, errs)
+			}
+			if !protobuf.Equal(got, tt.want) {
+				t.Errorf("decodeReport(%s) = %v, want %v", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	report := func(description, country string, weather proto.Weather) *proto.WeatherRequest {
+		return &proto.WeatherRequest{Description: description, Country: country, WeatherType: weather}
+	}
+	soleado := proto.Weather_WEATHER_SOLEADO
+	tests := []struct {
+		name    string
+		tweet   *proto.WeatherRequest
+		want    *proto.WeatherRequest // reporte normalizado
+		wantErr string
+	}{
+		{
+			name:  "valid report",
+			tweet: report("Sol", "GT", soleado),
+			want:  report("Sol", "GT", soleado),
+		},
+		{
+			name:  "description and country are trimmed",
+			tweet: report("  Sol \n", " gt ", soleado),
+			want:  report("Sol", "GT", soleado),
+		},
+		{
+			name:  "alpha-3 code",
+			tweet: report("Sol", "gtm", soleado),
+			want:  report("Sol", "GT", soleado),
+		},
+		{
+			name:  "alias",
+			tweet: report("Sol", "EEUU", soleado),
+			want:  report("Sol", "US", soleado),
+		},
+		{
+			name:  "alias ignores case",
+			tweet: report("Sol", "uk", soleado),
+			want:  report("Sol", "GB", soleado),
+		},
+		{
+			name:  "description at the limit, counted in characters",
+			tweet: report(strings.Repeat("ñ", maxDescriptionLength), "GT", soleado),
+			want:  report(strings.Repeat("ñ", maxDescriptionLength), "GT", soleado),
+		},
+		{
+			name:    "description over the limit",
+			tweet:   report(strings.Repeat("a", maxDescriptionLength+1), "GT", soleado),
+			wantErr: "description: description has 281 characters, the maximum is 280",
+		},
+		{
+			name:    "blank description",
+			tweet:   report(" \t", "GT", soleado),
+			wantErr: "description: description is required",
+		},
+		{
+			name:    "unknown country keeps what the client sent",
+			tweet:   report("Sol", " Guatemala", soleado),
+			wantErr: `country: " Guatemala" is not an ISO 3166-1 country code`,
+		},
+		{
+			name:    "alpha-3 code that does not exist",
+			tweet:   report("Sol", "XYZ", soleado),
+			wantErr: `country: "XYZ" is not an ISO 3166-1 country code`,
+		},
+		{
+			name:    "unknown weather value",
+			tweet:   report("Sol", "GT", proto.Weather(9)),
+			wantErr: "weather: 9 is not one of lluvioso, nubloso, soleado",
+		},
+		{
+			name:    "every missing field",
+			tweet:   &proto.WeatherRequest{},
+			wantErr: "description: description is required; country: country is required; weather: weather is required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRequest(tt.tweet)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("validateRequest() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateRequest(): %v", err)
+			}
+			if !protobuf.Equal(tt.tweet, tt.want) {
+				t.Errorf("validateRequest() normalized to %v, want %v", tt.tweet, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountryTables(t *testing.T) {
+	for alias, alpha2 := range countryAliases {
+		if !countryAlpha2[alpha2] {
+			t.Errorf("alias %s maps to unknown code %s", alias, alpha2)
+		}
+		if _, ok := countryAlpha3[alias]; ok {
+			t.Errorf("alias %s shadows an alpha-3 code", alias)
+		}
+	}
+	for alpha3, alpha2 := range countryAlpha3 {
+		if len(alpha3) != 3 || len(alpha2) != 2 || strings.ToUpper(alpha3) != alpha3 || strings.ToUpper(alpha2) != alpha2 {
+			t.Errorf("malformed entry %q: %q", alpha3, alpha2)
+		}
+	}
+}
+
+func TestValidateRequestLegacyWeather(t *testing.T) {
+	tests := []struct {
+		name    string
+		tweet   *proto.WeatherRequest
+		want    proto.Weather
+		wantErr string
+	}{
+		{
+			name:  "legacy weather text",
+			tweet: &proto.WeatherRequest{Description: "sol", Country: "GT", Weather: "Soleado"},
+			want:  proto.Weather_WEATHER_SOLEADO,
+		},
+		{
+			name:  "weather_type wins",
+			tweet: &proto.WeatherRequest{Description: "sol", Country: "GT", Weather: "lluvioso", WeatherType: proto.Weather_WEATHER_SOLEADO},
+			want:  proto.Weather_WEATHER_SOLEADO,
+		},
+		{
+			name:    "unknown legacy weather",
+			tweet:   &proto.WeatherRequest{Description: "sol", Country: "GT", Weather: "granizo"},
+			wantErr: `weather: "granizo" is not one of lluvioso, nubloso, soleado`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRequest(tt.tweet)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("validateRequest() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateRequest(): %v", err)
+			}
+			if tt.tweet.GetWeatherType() != tt.want || tt.tweet.GetWeather() != "" {
+				t.Errorf("weather_type = %v, weather = %q; want %v and no legacy text", tt.tweet.GetWeatherType(), tt.tweet.GetWeather(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"servidor-api-go/internal/proto"
+)
+
+// writerBatchSize es la cantidad máxima de reportes por RPC de lote. Cuando un
+// writer recibe más reportes de un mismo lote, se le envían todos en un solo
+// StreamPublish en vez de en varias RPC de lote.
+const writerBatchSize = 200
+
+// backend es un writer detrás de PublisherService. La conexión se establece
+// en segundo plano: el writer puede arrancar después que el entrypoint o
+// reiniciarse, y gRPC se reconecta solo.
+type backend struct {
+	addr    string
+	conn    *grpc.ClientConn
+	client  proto.PublisherServiceClient
+	breaker *circuitBreaker
+
+	mu        sync.RWMutex
+	name      string // backend que informa Describe, por ejemplo "kafka"; la dirección hasta entonces
+	described bool
+	policy    callPolicy
+	state     connectivity.State
+}
+
+// fanout publica cada reporte en los writers que indique la política de enrutamiento.
+type fanout struct {
+	backends []*backend
+	policy   atomic.Pointer[routingPolicy]
+	spool    *spool      // nil si el spool está deshabilitado
+	quorum   writeQuorum // cuántos writers del plan deben confirmar
+	ready    readiness   // qué writers deben estar conectados para /ready
+
+	stop context.CancelFunc
+}
+
+func newFanout(addrs []string) *fanout {
+	ctx, stop := context.WithCancel(context.Background())
+	f := &fanout{stop: stop}
+	for _, addr := range addrs {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		conn, err := setupGRPCConn(addr)
+		if err != nil {
+			log.Fatalf("Invalid writer address %q: %v", addr, err)
+		}
+		b := &backend{
+			addr:    addr,
+			conn:    conn,
+			client:  proto.NewPublisherServiceClient(conn),
+			name:    addr,
+			policy:  newCallPolicyFromEnv(addr),
+			breaker: newCircuitBreakerFromEnv(addr),
+		}
+		go b.watch(ctx, f.writerReady)
+		f.backends = append(f.backends, b)
+	}
+	if len(f.backends) == 0 {
+		log.Fatalf("No writers configured")
+	}
+
+	quorum, err := parseWriteQuorum(getEnv("WRITE_QUORUM", "all"))
+	if err != nil {
+		log.Fatalf("Invalid WRITE_QUORUM: %v", err)
+	}
+	f.quorum = quorum
+	f.ready = parseReadiness(getEnv("READY_WRITERS", "any"))
+
+	cfg, err := loadRoutingConfig()
+	if err != nil {
+		log.Fatalf("Failed to load routing policy: %v", err)
+	}
+	if err := f.setPolicy(cfg); err != nil {
+		log.Fatalf("Invalid routing policy: %v", err)
+	}
+	return f
+}
+
+// label devuelve el nombre actual del writer.
+func (b *backend) label() string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.name
+}
+
+func (b *backend) callPolicy() callPolicy {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.policy
+}
+
+func (b *backend) connState() connectivity.State {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.state
+}
+
+// watch sigue el estado de la conexión hasta que ctx se cancele. Mantiene la
+// conexión activa y, la primera vez que queda lista, pregunta al writer qué
+// backend representa. Cada vez que la conexión se recupera llama a onReady.
+func (b *backend) watch(ctx context.Context, onReady func()) {
+	b.conn.Connect()
+	for {
+		state := b.conn.GetState()
+		b.mu.Lock()
+		previous := b.state
+		b.state = state
+		described := b.described
+		b.mu.Unlock()
+
+		if state != previous {
+			log.Printf("Writer %s connection state: %v", b.label(), state)
+		}
+		switch state {
+		case connectivity.Ready:
+			if !described {
+				b.describe(ctx)
+			}
+			if state != previous {
+				// No esperar el cooldown del breaker: la próxima llamada sirve de prueba
+				b.breaker.probe()
+				onReady()
+			}
+		case connectivity.Idle:
+			// Sin tráfico gRPC deja la conexión inactiva; reconectar para que /ready no mienta
+			b.conn.Connect()
+		}
+
+		if !b.conn.WaitForStateChange(ctx, state) {
+			return
+		}
+	}
+}
+
+// describe pregunta al writer qué backend representa y carga la política de
+// reintentos y el breaker configurados para ese nombre. Si falla se conserva
+// la dirección como nombre y se vuelve a intentar en la próxima reconexión.
+func (b *backend) describe(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	resp, err := b.client.Describe(ctx, &proto.DescribeRequest{})
+	if err != nil {
+		log.Printf("Failed to describe writer at %s: %v", b.addr, err)
+		return
+	}
+
+	name := resp.GetBackend()
+	b.mu.Lock()
+	b.name = name
+	b.described = true
+	b.policy = newCallPolicyFromEnv(name)
+	b.mu.Unlock()
+	b.breaker.configure(name)
+	log.Printf("Writer at %s publishes to %s (%s)", b.addr, name, resp.GetDestination())
+}
+
+// writerReady se llama cuando un writer se (re)conecta: reanuda el reenvío del spool.
+func (f *fanout) writerReady() {
+	if f.spool != nil {
+		f.spool.notify()
+	}
+}
+
+func (f *fanout) close() {
+	f.stop()
+	for _, b := range f.backends {
+		b.conn.Close()
+	}
+}
+
+// lookup busca un writer por nombre de backend o por dirección.
+func (f *fanout) lookup(name string) *backend {
+	for _, b := range f.backends {
+		if b.label() == name || b.addr == name {
+			return b
+		}
+	}
+	return nil
+}
+
+// resolve traduce nombres a writers; una lista vacía significa todos.
+func (f *fanout) resolve(names []string) []*backend {
+	if len(names) == 0 {
+		return f.backends
+	}
+	var backends []*backend
+	for _, name := range names {
+		if b := f.lookup(name); b != nil {
+			backends = append(backends, b)
+		}
+	}
+	return backends
+}
+
+// publish enruta el reporte según la política activa: lo envía en paralelo a
+// los writers del plan y, si alguno falla, prueba los de respaldo en orden
+// para reponer las copias que falten.
+func (f *fanout) publish(ctx context.Context, tweet *proto.WeatherRequest) *publishOutcome {
+	return f.publishPlan(ctx, tweet, f.policy.Load().route(tweet))
+}
+
+// publishPlan publica el reporte según un plan ya decidido.
+func (f *fanout) publishPlan(ctx context.Context, tweet *proto.WeatherRequest, plan routePlan) *publishOutcome {
+	targets := f.resolve(plan.targets)
+	outcome := f.newOutcome(targets)
+	if len(targets) == 0 {
+		log.Printf("No writers available for route %v", plan.targets)
+		return outcome
+	}
+
+	outcome.Backends = f.publishTo(ctx, targets, tweet, false)
+	for _, name := range plan.fallbacks {
+		if outcome.complete() {
+			break
+		}
+		b := f.lookup(name)
+		if b == nil {
+			continue
+		}
+		log.Printf("Falling back to %s: %v", b.label(), outcome.err())
+		outcome.Backends = append(outcome.Backends, f.publishTo(ctx, []*backend{b}, tweet, true)...)
+	}
+	return outcome
+}
+
+// newOutcome prepara el resultado de publicar en targets según el quorum configurado.
+func (f *fanout) newOutcome(targets []*backend) *publishOutcome {
+	outcome := &publishOutcome{Quorum: f.quorum.String(), Required: f.quorum.required(len(targets))}
+	for _, b := range targets {
+		outcome.targets = append(outcome.targets, b.label())
+	}
+	return outcome
+}
+
+// publishTo envía el reporte a los writers indicados en paralelo y devuelve el
+// resultado de cada uno.
+func (f *fanout) publishTo(ctx context.Context, backends []*backend, tweet *proto.WeatherRequest, fallback bool) []backendResult {
+	var wg sync.WaitGroup
+	results := make([]backendResult, len(backends))
+
+	for i, b := range backends {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			var resp *proto.WeatherResponse
+			_, err := b.invoke(ctx, func(ctx context.Context) (string, error) {
+				var err error
+				resp, err = b.client.Publish(ctx, tweet)
+				if err != nil || resp.GetSuccess() {
+					return "", err
+				}
+				return resp.GetCode(), nil
+			})
+			results[i] = newBackendResult(b.label(), time.Since(start), err, resp.GetSuccess(), resp.GetCode(), resp.GetMessage())
+			results[i].Fallback = fallback
+			if results[i].Status == statusFailed {
+				log.Printf("%s publish error: %s", b.label(), results[i].Error)
+			} else {
+				log.Printf("%s publish success: %s", b.label(), tweet.GetId())
+			}
+		}()
+	}
+
+	wg.Wait()
+	return results
+}
+
+// publishBatch enruta cada reporte con route y los agrupa por
+// writer para usar la RPC de lote. Los reportes a los que les faltan copias se
+// reintentan con sus writers de respaldo, una ronda por respaldo. Devuelve el
+// resultado de cada reporte.
+func (f *fanout) publishBatch(ctx context.Context, tweets []*proto.WeatherRequest, route func(*proto.WeatherRequest) routePlan) []*publishOutcome {
+	plans := make([]routePlan, len(tweets))
+	outcomes := make([]*publishOutcome, len(tweets))
+
+	assignments := make(map[*backend][]int)
+	for j, tweet := range tweets {
+		plans[j] = route(tweet)
+		targets := f.resolve(plans[j].targets)
+		outcomes[j] = f.newOutcome(targets)
+		if len(targets) == 0 {
+			log.Printf("No writers available for route %v", plans[j].targets)
+			continue
+		}
+		for _, b := range targets {
+			assignments[b] = append(assignments[b], j)
+		}
+	}
+	f.sendAssignments(ctx, tweets, assignments, outcomes, false)
+
+	for round := 0; ; round++ {
+		assignments = make(map[*backend][]int)
+		for j, outcome := range outcomes {
+			if outcome.complete() || round >= len(plans[j].fallbacks) {
+				continue
+			}
+			if b := f.lookup(plans[j].fallbacks[round]); b != nil {
+				assignments[b] = append(assignments[b], j)
+			}
+		}
+		if len(assignments) == 0 {
+			break
+		}
+		f.sendAssignments(ctx, tweets, assignments, outcomes, true)
+	}
+	return outcomes
+}
+
+// sendAssignments publica en cada writer los reportes asignados y agrega el
+// resultado de cada writer al de cada reporte. Hasta writerBatchSize reportes
+// van en una RPC de lote; más, en un StreamPublish.
+func (f *fanout) sendAssignments(ctx context.Context, tweets []*proto.WeatherRequest, assignments map[*backend][]int, outcomes []*publishOutcome, fallback bool) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for b, indices := range assignments {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			requests := make([]*proto.WeatherRequest, len(indices))
+			for k, j := range indices {
+				requests[k] = tweets[j]
+			}
+
+			// Sólo se reintenta el envío completo si falla la llamada; los
+			// reportes que el writer rechaza pasan a los respaldos o al spool
+			begin := time.Now()
+			var resp *proto.WeatherBatchResponse
+			_, err := b.invoke(ctx, func(ctx context.Context) (string, error) {
+				var err error
+				if len(requests) > writerBatchSize {
+					resp, err = b.streamPublish(ctx, requests)
+				} else {
+					resp, err = b.client.PublishBatch(ctx, &proto.WeatherBatchRequest{Requests: requests})
+				}
+				return "", err
+			})
+			results := collectBatchResults(b.label(), time.Since(begin), resp, err, len(indices))
+
+			mu.Lock()
+			defer mu.Unlock()
+			for k, j := range indices {
+				results[k].Fallback = fallback
+				outcomes[j].Backends = append(outcomes[j].Backends, results[k])
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// streamPublish envía requests al writer en un solo StreamPublish; el writer
+// responde con un resultado por reporte, en el orden en que se enviaron.
+func (b *backend) streamPublish(ctx context.Context, requests []*proto.WeatherRequest) (*proto.WeatherBatchResponse, error) {
+	stream, err := b.client.StreamPublish(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, req := range requests {
+		// io.EOF indica que el writer cortó el flujo; CloseAndRecv trae el motivo
+		if err := stream.Send(req); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return stream.CloseAndRecv()
+}
+
+// collectBatchResults traduce la respuesta de una RPC de lote a un resultado por reporte.
+func collectBatchResults(backend string, latency time.Duration, resp *proto.WeatherBatchResponse, err error, n int) []backendResult {
+	results := make([]backendResult, n)
+	if err != nil {
+		log.Printf("%s batch publish error: %v", backend, err)
+		for i := range results {
+			results[i] = newBackendResult(backend, latency, err, false, "", "")
+		}
+		return results
+	}
+
+	log.Printf("%s batch publish: %d published, %d failed", backend, resp.GetPublished(), resp.GetFailed())
+	for i := range results {
+		results[i] = newBackendResult(backend, latency, nil, false, "MISSING_RESULT", "missing result")
+	}
+	for _, result := range resp.GetResults() {
+		index := int(result.GetIndex())
+		if index < 0 || index >= n {
+			continue
+		}
+		results[index] = newBackendResult(backend, latency, nil, result.GetSuccess(), result.GetCode(), result.GetMessage())
+	}
+	return results
+}
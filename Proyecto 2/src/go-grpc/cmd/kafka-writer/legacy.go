@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"servidor-api-go/internal/proto"
+)
+
+// legacyServer expone WeatherService para clientes que todavía llaman a los
+// métodos por broker. Los métodos de RabbitMQ fallan con FailedPrecondition
+// en lugar de responder un éxito falso.
+type legacyServer struct {
+	proto.UnimplementedWeatherServiceServer
+	publisher *kafkaServer
+}
+
+func (s *legacyServer) PublishToKafka(ctx context.Context, tweet *proto.WeatherRequest) (*proto.WeatherResponse, error) {
+	return s.publisher.Publish(ctx, tweet)
+}
+
+func (s *legacyServer) PublishBatchToKafka(ctx context.Context, batch *proto.WeatherBatchRequest) (*proto.WeatherBatchResponse, error) {
+	return s.publisher.PublishBatch(ctx, batch)
+}
+
+func (s *legacyServer) PublishToRabbitMQ(context.Context, *proto.WeatherRequest) (*proto.WeatherResponse, error) {
+	return nil, status.Error(codes.FailedPrecondition, "this writer only publishes to Kafka")
+}
+
+func (s *legacyServer) PublishBatchToRabbitMQ(context.Context, *proto.WeatherBatchRequest) (*proto.WeatherBatchResponse, error) {
+	return nil, status.Error(codes.FailedPrecondition, "this writer only publishes to Kafka")
+}
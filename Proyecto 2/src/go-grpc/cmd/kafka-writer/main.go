@@ -2,40 +2,48 @@ package main
 
 import (
 	"context"
+	"io"
 	"log"
 	"net"
 	"os"
-	"encoding/json"
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 	"google.golang.org/grpc"
+	"servidor-api-go/internal/message"
 	"servidor-api-go/internal/proto"
 )
 
-// Server implementa el servicio gRPC
+const (
+	// kafkaTopic es el topic donde se publican los reportes.
+	kafkaTopic = "weather-tweets"
+	// streamChunkSize es la cantidad de reportes de un flujo que se producen juntos.
+	streamChunkSize = 100
+)
+
+// Server implementa el servicio gRPC PublisherService sobre Kafka
 type kafkaServer struct {
-	proto.UnimplementedWeatherServiceServer
+	proto.UnimplementedPublisherServiceServer
 	producer *kafka.Producer
 }
 
-func (s *kafkaServer) PublishToKafka(ctx context.Context, tweet *proto.WeatherRequest) (*proto.WeatherResponse, error) {
-	
-	log.Printf("Received gRPC call PublishToKafka with tweet: %+v", tweet)
-	message := map[string]string{
-		"description": tweet.GetDescription(),
-		"country":     tweet.GetCountry(),
-		"weather":     tweet.GetWeather(),
-	}
+// Describe informa que este writer publica en Kafka.
+func (s *kafkaServer) Describe(ctx context.Context, _ *proto.DescribeRequest) (*proto.DescribeResponse, error) {
+	return &proto.DescribeResponse{Backend: "kafka", Destination: kafkaTopic}, nil
+}
 
-	jsonData, err := json.Marshal(message)
+func (s *kafkaServer) Publish(ctx context.Context, tweet *proto.WeatherRequest) (*proto.WeatherResponse, error) {
+	
+	log.Printf("Received gRPC call Publish with tweet: %+v", tweet)
+	jsonData, err := message.FromRequest(tweet).Marshal()
 	if err != nil {
 		log.Printf("Failed to marshal message: %v", err)
 		return &proto.WeatherResponse{
 			Success: false,
 			Message: "Failed to marshal message",
-		}, err
+			Code:    "MARSHAL_FAILED",
+		}, nil
 	}
 
-	topic := "weather-tweets"
+	topic := kafkaTopic
 	deliveryChan := make(chan kafka.Event)
 	defer close(deliveryChan)
 
@@ -43,6 +51,7 @@ func (s *kafkaServer) PublishToKafka(ctx context.Context, tweet *proto.WeatherRe
 
 	err = s.producer.Produce(&kafka.Message{
 		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Key:            []byte(tweet.GetId()),
 		Value:          jsonData,
 	}, deliveryChan)
 
@@ -51,7 +60,8 @@ func (s *kafkaServer) PublishToKafka(ctx context.Context, tweet *proto.WeatherRe
 		return &proto.WeatherResponse{
 			Success: false,
 			Message: "Failed to produce message",
-		}, err
+			Code:    "PRODUCE_FAILED",
+		}, nil
 	}
 
 	e := <-deliveryChan
@@ -62,7 +72,8 @@ func (s *kafkaServer) PublishToKafka(ctx context.Context, tweet *proto.WeatherRe
 		return &proto.WeatherResponse{
 			Success: false,
 			Message: "Delivery failed",
-		}, m.TopicPartition.Error
+			Code:    "DELIVERY_FAILED",
+		}, nil
 	}
 
 	log.Printf("Message successfully published to Kafka topic %s [%d] at offset %v",
@@ -74,11 +85,107 @@ func (s *kafkaServer) PublishToKafka(ctx context.Context, tweet *proto.WeatherRe
 	}, nil
 }
 
-func (s *kafkaServer) PublishToRabbitMQ(ctx context.Context, tweet *proto.WeatherRequest) (*proto.WeatherResponse, error) {
-	return &proto.WeatherResponse{
-		Success: false,
-		Message: "This service only handles Kafka messages",
-	}, nil
+// PublishBatch produce todos los reportes del lote sin esperar uno por uno y
+// luego recoge el reporte de entrega de cada mensaje.
+func (s *kafkaServer) PublishBatch(ctx context.Context, batch *proto.WeatherBatchRequest) (*proto.WeatherBatchResponse, error) {
+	log.Printf("Received gRPC call PublishBatch with %d tweets", len(batch.GetRequests()))
+	return s.publishBatch(batch.GetRequests()), nil
+}
+
+// StreamPublish recibe un flujo de reportes y los produce en bloques de
+// streamChunkSize; al cerrar el flujo responde con el resultado de cada uno.
+func (s *kafkaServer) StreamPublish(stream proto.PublisherService_StreamPublishServer) error {
+	resp := &proto.WeatherBatchResponse{}
+	var pending []*proto.WeatherRequest
+
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		offset := int32(len(resp.Results))
+		chunk := s.publishBatch(pending)
+		for _, result := range chunk.Results {
+			result.Index += offset
+		}
+		resp.Results = append(resp.Results, chunk.Results...)
+		resp.Published += chunk.Published
+		resp.Failed += chunk.Failed
+		pending = nil
+	}
+
+	for {
+		tweet, err := stream.Recv()
+		if err == io.EOF {
+			flush()
+			log.Printf("StreamPublish finished: %d published, %d failed", resp.Published, resp.Failed)
+			return stream.SendAndClose(resp)
+		}
+		if err != nil {
+			log.Printf("StreamPublish receive error: %v", err)
+			return err
+		}
+		pending = append(pending, tweet)
+		if len(pending) >= streamChunkSize {
+			flush()
+		}
+	}
+}
+
+// publishBatch produce los reportes y espera sus reportes de entrega. El índice
+// de cada mensaje viaja en Opaque para asociar la entrega con su resultado.
+func (s *kafkaServer) publishBatch(tweets []*proto.WeatherRequest) *proto.WeatherBatchResponse {
+	resp := &proto.WeatherBatchResponse{Results: make([]*proto.WeatherResult, len(tweets))}
+	deliveryChan := make(chan kafka.Event, len(tweets))
+	topic := kafkaTopic
+	expected := 0
+
+	for i, tweet := range tweets {
+		resp.Results[i] = &proto.WeatherResult{Index: int32(i), Id: tweet.GetId()}
+
+		jsonData, err := message.FromRequest(tweet).Marshal()
+		if err != nil {
+			resp.Results[i].Message = "Failed to marshal message"
+			resp.Results[i].Code = "MARSHAL_FAILED"
+			continue
+		}
+
+		err = s.producer.Produce(&kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+			Key:            []byte(tweet.GetId()),
+			Value:          jsonData,
+			Opaque:         i,
+		}, deliveryChan)
+		if err != nil {
+			log.Printf("Failed to produce message to Kafka: %v", err)
+			resp.Results[i].Message = "Failed to produce message"
+			resp.Results[i].Code = "PRODUCE_FAILED"
+			continue
+		}
+		expected++
+	}
+
+	for ; expected > 0; expected-- {
+		m := (<-deliveryChan).(*kafka.Message)
+		result := resp.Results[m.Opaque.(int)]
+		if m.TopicPartition.Error != nil {
+			log.Printf("Delivery failed: %v", m.TopicPartition.Error)
+			result.Message = "Delivery failed"
+			result.Code = "DELIVERY_FAILED"
+			continue
+		}
+		result.Success = true
+		result.Message = "Message published to Kafka"
+	}
+
+	for _, result := range resp.Results {
+		if result.Success {
+			resp.Published++
+		} else {
+			resp.Failed++
+		}
+	}
+	log.Printf("Batch published to Kafka: %d published, %d failed", resp.Published, resp.Failed)
+	return resp
 }
 
 func main() {
@@ -103,7 +210,9 @@ func main() {
 	}
 
 	s := grpc.NewServer()
-	proto.RegisterWeatherServiceServer(s, &kafkaServer{producer: producer})
+	server := &kafkaServer{producer: producer}
+	proto.RegisterPublisherServiceServer(s, server)
+	proto.RegisterWeatherServiceServer(s, &legacyServer{publisher: server})
 
 	log.Println("Kafka Writer gRPC server listening on :50051 :)")
 	if err := s.Serve(lis); err != nil {
@@ -2,117 +2,324 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
+	"strconv"
 	"time"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"google.golang.org/grpc"
+	"servidor-api-go/internal/message"
 	"servidor-api-go/internal/proto" // Ajusta la ruta a tu módulo
+	"servidor-api-go/topology"
 )
 
-// Server implementa el servicio gRPC
+// streamChunkSize es la cantidad de reportes de un flujo que se publican juntos.
+const streamChunkSize = 100
+
+var (
+	// errNacked indica que el broker rechazó (basic.nack) la publicación.
+	errNacked = errors.New("message nacked by broker")
+	// errReturned indica que el broker devolvió el mensaje por no poder enrutarlo (mandatory).
+	errReturned = errors.New("message returned by broker as unroutable")
+)
+
+// Server implementa el servicio gRPC PublisherService sobre RabbitMQ
 type rabbitMQServer struct {
-	proto.UnimplementedWeatherServiceServer
-	conn *amqp.Connection
+	proto.UnimplementedPublisherServiceServer
+	pool *channelPool
 }
 
-func (s *rabbitMQServer) PublishToRabbitMQ(ctx context.Context, tweet *proto.WeatherRequest) (*proto.WeatherResponse, error) {
-	
-	log.Printf("Received gRPC call PublishToRabbitMQ with tweet: %+v", tweet)
-	
-	ch, err := s.conn.Channel()
+// Describe informa que este writer publica en RabbitMQ.
+func (s *rabbitMQServer) Describe(ctx context.Context, _ *proto.DescribeRequest) (*proto.DescribeResponse, error) {
+	return &proto.DescribeResponse{Backend: "rabbitmq", Destination: queueName}, nil
+}
+
+func (s *rabbitMQServer) Publish(ctx context.Context, tweet *proto.WeatherRequest) (*proto.WeatherResponse, error) {
 	
+	log.Printf("Received gRPC call Publish with tweet: %+v", tweet)
+
+	msg := message.FromRequest(tweet)
+	body, err := msg.Marshal()
 	if err != nil {
-		log.Printf("Failed to open RabbitMQ channel: %v", err)
+		log.Printf("Failed to marshal message: %v", err)
 		return &proto.WeatherResponse{
 			Success: false,
-			Message: "Failed to open channel",
-		}, err
+			Message: "Failed to marshal message",
+			Code:    "MARSHAL_FAILED",
+		}, nil
 	}
-	defer ch.Close()
 
-	q, err := ch.QueueDeclare(
-		"weather-tweets", // name
-		true,             // durable
-		false,            // delete when unused
-		false,            // exclusive
-		false,            // no-wait
-		nil,              // arguments
-	)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// Canal en modo confirmación tomado del pool: el broker responde ack/nack
+	// por cada publicación, así sólo reportamos éxito cuando el mensaje quedó
+	// persistido en la cola.
+	c, err := s.pool.Get(ctx)
 	if err != nil {
-		log.Printf("Failed to declare queue: %v", err)
+		log.Printf("Failed to get RabbitMQ channel: %v", err)
 		return &proto.WeatherResponse{
 			Success: false,
-			Message: "Failed to declare queue",
-		}, err
+			Message: "Failed to open channel",
+			Code:    "CHANNEL_UNAVAILABLE",
+		}, nil
 	}
 
-	message := map[string]string{
-		"description": tweet.GetDescription(),
-		"country":     tweet.GetCountry(),
-		"weather":     tweet.GetWeather(),
-	}
+	log.Printf("Attempting to publish message to RabbitMQ queue %s", queueName)
 
-	body, err := json.Marshal(message)
+	err = publishConfirmed(ctx, c, msg, body)
+	s.pool.Put(c, err != nil)
 	if err != nil {
-		log.Printf("Failed to marshal message: %v", err)
+		log.Printf("RabbitMQ did not confirm message on queue %s: %v", queueName, err)
+		reason, code := "Failed to publish message", "PUBLISH_FAILED"
+		switch {
+		case errors.Is(err, errNacked):
+			reason, code = "Message nacked by RabbitMQ", "NACKED"
+		case errors.Is(err, errReturned):
+			reason, code = "Message returned by RabbitMQ", "RETURNED"
+		}
 		return &proto.WeatherResponse{
 			Success: false,
-			Message: "Failed to marshal message",
-		}, err
+			Message: reason,
+			Code:    code,
+		}, nil
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
+	log.Printf("Message confirmed by RabbitMQ on queue %s", queueName)
 
-	log.Printf("Attempting to publish message to RabbitMQ queue %s", q.Name)
+	return &proto.WeatherResponse{
+		Success: true,
+		Message: "Message published to RabbitMQ",
+	}, nil
+}
 
-	err = ch.PublishWithContext(ctx,
-		"",     // exchange
-		q.Name, // routing key
-		false,  // mandatory
-		false,  // immediate
+// publishConfirmed publica body en la cola y espera el ack del broker. Un
+// basic.return siempre llega antes que su ack, por lo que basta revisar el
+// canal de retornos una vez recibida la confirmación.
+func publishConfirmed(ctx context.Context, c *confirmChannel, msg message.WeatherMessage, body []byte) error {
+	confirm, err := c.ch.PublishWithDeferredConfirmWithContext(ctx,
+		"",        // exchange
+		queueName, // routing key
+		true,      // mandatory
+		false,     // immediate
 		amqp.Publishing{
 			DeliveryMode: amqp.Persistent,
-			ContentType: "application/json",
-			Body:        body,
+			ContentType:  "application/json",
+			MessageId:    msg.ID,
+			Timestamp:    msg.IngestedAt,
+			Body:         body,
 		},
 	)
+	if err != nil {
+		return err
+	}
 
+	acked, err := confirm.WaitContext(ctx)
 	if err != nil {
-		log.Printf("Failed to publish message to RabbitMQ: %v", err)
-		return &proto.WeatherResponse{
-			Success: false,
-			Message: "Failed to publish message",
-		}, err
+		return fmt.Errorf("waiting for publisher confirm: %w", err)
+	}
+	if !acked {
+		return errNacked
 	}
 
-	log.Printf("Message successfully published to RabbitMQ queue %s", q.Name)
+	select {
+	case ret, ok := <-c.returns:
+		if !ok {
+			// El canal se cerró después del ack; el mensaje ya fue aceptado.
+			return nil
+		}
+		return fmt.Errorf("%w: %d %s", errReturned, ret.ReplyCode, ret.ReplyText)
+	default:
+		return nil
+	}
+}
 
-	return &proto.WeatherResponse{
-		Success: true,
-		Message: "Message published to RabbitMQ",
-	}, nil
+// PublishBatch publica todos los reportes del lote en un mismo canal y luego
+// espera la confirmación del broker para cada uno.
+func (s *rabbitMQServer) PublishBatch(ctx context.Context, batch *proto.WeatherBatchRequest) (*proto.WeatherBatchResponse, error) {
+	log.Printf("Received gRPC call PublishBatch with %d tweets", len(batch.GetRequests()))
+	return s.publishBatch(ctx, batch.GetRequests()), nil
 }
 
-func (s *rabbitMQServer) PublishToKafka(ctx context.Context, tweet *proto.WeatherRequest) (*proto.WeatherResponse, error) {
-	return &proto.WeatherResponse{
-		Success: false,
-		Message: "This service only handles RabbitMQ messages",
-	}, nil
+// StreamPublish recibe un flujo de reportes y los publica en bloques de
+// streamChunkSize; al cerrar el flujo responde con el resultado de cada uno.
+func (s *rabbitMQServer) StreamPublish(stream proto.PublisherService_StreamPublishServer) error {
+	resp := &proto.WeatherBatchResponse{}
+	var pending []*proto.WeatherRequest
+
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		offset := int32(len(resp.Results))
+		chunk := s.publishBatch(stream.Context(), pending)
+		for _, result := range chunk.Results {
+			result.Index += offset
+		}
+		resp.Results = append(resp.Results, chunk.Results...)
+		resp.Published += chunk.Published
+		resp.Failed += chunk.Failed
+		pending = nil
+	}
+
+	for {
+		tweet, err := stream.Recv()
+		if err == io.EOF {
+			flush()
+			log.Printf("StreamPublish finished: %d published, %d failed", resp.Published, resp.Failed)
+			return stream.SendAndClose(resp)
+		}
+		if err != nil {
+			log.Printf("StreamPublish receive error: %v", err)
+			return err
+		}
+		pending = append(pending, tweet)
+		if len(pending) >= streamChunkSize {
+			flush()
+		}
+	}
 }
 
-func main() {
-	// RabbitMQ Connection
-	conn, err := amqp.Dial(getEnv("RABBITMQ_URL", "amqp://rabbitmq:5672"))
+// publishBatch publica los reportes en un canal del pool y espera el ack de
+// cada uno. Los retornos (mandatory) se asocian por CorrelationId, que lleva
+// el índice del reporte dentro del lote.
+func (s *rabbitMQServer) publishBatch(ctx context.Context, tweets []*proto.WeatherRequest) *proto.WeatherBatchResponse {
+	resp := &proto.WeatherBatchResponse{Results: make([]*proto.WeatherResult, len(tweets))}
+	for i, tweet := range tweets {
+		resp.Results[i] = &proto.WeatherResult{Index: int32(i), Id: tweet.GetId()}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	c, err := s.pool.Get(ctx)
 	if err != nil {
-		log.Fatalf("Failed to connect to RabbitMQ: %v", err)
+		log.Printf("Failed to get RabbitMQ channel: %v", err)
+		for _, result := range resp.Results {
+			result.Message = "Failed to open channel"
+			result.Code = "CHANNEL_UNAVAILABLE"
+		}
+		resp.Failed = int32(len(tweets))
+		return resp
+	}
+
+	// Recolectar retornos mientras se publica: el canal de retornos tiene
+	// buffer 1 y un retorno sin leer bloquearía la llegada de los acks.
+	returned := make(map[string]amqp.Return)
+	stop := make(chan struct{})
+	collected := make(chan struct{})
+	go func() {
+		defer close(collected)
+		for {
+			select {
+			case ret, ok := <-c.returns:
+				if !ok {
+					return
+				}
+				returned[ret.CorrelationId] = ret
+			case <-stop:
+				// Un retorno siempre llega antes que su ack: sólo queda el que esté en el buffer
+				select {
+				case ret, ok := <-c.returns:
+					if ok {
+						returned[ret.CorrelationId] = ret
+					}
+				default:
+				}
+				return
+			}
+		}
+	}()
+
+	confirms := make([]*amqp.DeferredConfirmation, len(tweets))
+	failed := false
+	for i, tweet := range tweets {
+		msg := message.FromRequest(tweet)
+		body, err := msg.Marshal()
+		if err != nil {
+			resp.Results[i].Message = "Failed to marshal message"
+			resp.Results[i].Code = "MARSHAL_FAILED"
+			continue
+		}
+		confirms[i], err = c.ch.PublishWithDeferredConfirmWithContext(ctx,
+			"",        // exchange
+			queueName, // routing key
+			true,      // mandatory
+			false,     // immediate
+			amqp.Publishing{
+				DeliveryMode:  amqp.Persistent,
+				ContentType:   "application/json",
+				MessageId:     msg.ID,
+				CorrelationId: strconv.Itoa(i),
+				Timestamp:     msg.IngestedAt,
+				Body:          body,
+			},
+		)
+		if err != nil {
+			log.Printf("Failed to publish message to RabbitMQ: %v", err)
+			resp.Results[i].Message = "Failed to publish message"
+			resp.Results[i].Code = "PUBLISH_FAILED"
+			failed = true
+		}
+	}
+
+	for i, confirm := range confirms {
+		if confirm == nil {
+			continue
+		}
+		acked, err := confirm.WaitContext(ctx)
+		switch {
+		case err != nil:
+			resp.Results[i].Message = "Publish confirmation failed"
+			resp.Results[i].Code = "PUBLISH_FAILED"
+			failed = true
+		case !acked:
+			resp.Results[i].Message = "Message nacked by RabbitMQ"
+			resp.Results[i].Code = "NACKED"
+		default:
+			resp.Results[i].Success = true
+			resp.Results[i].Message = "Message published to RabbitMQ"
+		}
 	}
-	defer conn.Close()
 
-	log.Println("Connected to RabbitMQ")
+	close(stop)
+	<-collected
+	for i, result := range resp.Results {
+		if ret, ok := returned[strconv.Itoa(i)]; ok && result.Success {
+			log.Printf("Message %d returned by RabbitMQ: %d %s", i, ret.ReplyCode, ret.ReplyText)
+			result.Success = false
+			result.Message = "Message returned by RabbitMQ"
+			result.Code = "RETURNED"
+		}
+	}
+	s.pool.Put(c, failed || len(returned) > 0)
+
+	for _, result := range resp.Results {
+		if result.Success {
+			resp.Published++
+		} else {
+			resp.Failed++
+		}
+	}
+	log.Printf("Batch published to RabbitMQ: %d published, %d failed", resp.Published, resp.Failed)
+	return resp
+}
+
+func main() {
+	// RabbitMQ Connection: el pool se conecta en segundo plano y se reconecta
+	// si el broker se reinicia.
+	poolSize, err := strconv.Atoi(getEnv("RABBITMQ_CHANNEL_POOL_SIZE", "8"))
+	if err != nil || poolSize < 1 {
+		log.Fatalf("Invalid RABBITMQ_CHANNEL_POOL_SIZE: %q", getEnv("RABBITMQ_CHANNEL_POOL_SIZE", "8"))
+	}
+	// La topología (DLX, DLQ, reintentos y TTL) es la misma que declara el consumidor
+	pool := newChannelPool(getEnv("RABBITMQ_URL", "amqp://rabbitmq:5672"), poolSize, topology.ConfigFromEnv())
+	go pool.run()
+	defer pool.Close()
 
 	// gRPC Server
 	lis, err := net.Listen("tcp", ":50052")
@@ -121,7 +328,9 @@ func main() {
 	}
 
 	s := grpc.NewServer()
-	proto.RegisterWeatherServiceServer(s, &rabbitMQServer{conn: conn})
+	server := &rabbitMQServer{pool: pool}
+	proto.RegisterPublisherServiceServer(s, server)
+	proto.RegisterWeatherServiceServer(s, &legacyServer{publisher: server})
 
 	log.Println("RabbitMQ Writer gRPC server listening on :50052")
 	if err := s.Serve(lis); err != nil {
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"servidor-api-go/topology"
+)
+
+const (
+	queueName         = topology.Queue
+	minReconnectDelay = 500 * time.Millisecond
+	maxReconnectDelay = 30 * time.Second
+)
+
+var errPoolClosed = errors.New("rabbitmq channel pool closed")
+
+// confirmChannel es un canal AMQP en modo confirmación junto con su canal de
+// retornos. Sólo una publicación lo usa a la vez.
+type confirmChannel struct {
+	ch      *amqp.Channel
+	returns chan amqp.Return
+}
+
+// channelPool mantiene una conexión a RabbitMQ y un conjunto de canales en modo
+// confirmación reutilizables. Cuando la conexión se cae, la vuelve a abrir con
+// backoff exponencial y re-declara la topología.
+//
+// Nunca hay más de size canales abiertos, ociosos o en uso: una ráfaga de
+// publicaciones espera un canal libre en vez de abrir uno por pedido hasta
+// llegar al channel_max del broker.
+type channelPool struct {
+	url      string
+	topology topology.Config
+	idle     chan *confirmChannel
+	open     chan struct{} // un lugar ocupado por cada canal abierto
+
+	mu    sync.Mutex
+	conn  *amqp.Connection
+	ready chan struct{} // se cierra cuando hay una conexión disponible
+	lost  chan struct{} // se cierra cuando reset descarta conn
+
+	done chan struct{}
+	once sync.Once
+}
+
+func newChannelPool(url string, size int, topo topology.Config) *channelPool {
+	return &channelPool{
+		url:      url,
+		topology: topo,
+		idle:     make(chan *confirmChannel, size),
+		open:     make(chan struct{}, size),
+		ready:    make(chan struct{}),
+		lost:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// run conecta y reconecta hasta que se cierre el pool.
+func (p *channelPool) run() {
+	delay := minReconnectDelay
+	for {
+		conn, err := p.connect()
+		if err != nil {
+			log.Printf("Failed to connect to RabbitMQ, retrying in %v: %v", delay, err)
+			select {
+			case <-time.After(delay):
+			case <-p.done:
+				return
+			}
+			delay *= 2
+			if delay > maxReconnectDelay {
+				delay = maxReconnectDelay
+			}
+			continue
+		}
+		delay = minReconnectDelay
+
+		closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+		p.mu.Lock()
+		p.conn = conn
+		close(p.ready)
+		p.mu.Unlock()
+		log.Println("Connected to RabbitMQ")
+
+		select {
+		case err := <-closed:
+			log.Printf("RabbitMQ connection closed: %v", err)
+			p.reset()
+		case <-p.done:
+			conn.Close()
+			return
+		}
+	}
+}
+
+// connect abre la conexión y declara la topología que comparte con el consumidor.
+func (p *channelPool) connect() (*amqp.Connection, error) {
+	conn, err := amqp.Dial(p.url)
+	if err != nil {
+		return nil, err
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("opening channel: %w", err)
+	}
+	defer ch.Close()
+
+	if _, err := topology.Declare(ch, p.topology); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("declaring topology: %w", err)
+	}
+	return conn, nil
+}
+
+// reset descarta la conexión caída y los canales ociosos asociados a ella.
+func (p *channelPool) reset() {
+	p.mu.Lock()
+	p.conn = nil
+	p.ready = make(chan struct{})
+	close(p.lost)
+	p.lost = make(chan struct{})
+	p.mu.Unlock()
+
+	for {
+		select {
+		case c := <-p.idle:
+			p.discard(c)
+		default:
+			return
+		}
+	}
+}
+
+// Get devuelve un canal en modo confirmación, reutilizando uno ocioso si existe.
+// Si ya hay size canales abiertos espera a que se libere uno, y si no hay
+// conexión espera a que se restablezca; en ambos casos hasta que expire ctx.
+func (p *channelPool) Get(ctx context.Context) (*confirmChannel, error) {
+	for {
+		select {
+		case c := <-p.idle:
+			if !c.ch.IsClosed() {
+				return c, nil
+			}
+			p.discard(c)
+			continue
+		default:
+		}
+
+		select {
+		case c := <-p.idle:
+			if !c.ch.IsClosed() {
+				return c, nil
+			}
+			p.discard(c)
+			continue
+		case p.open <- struct{}{}:
+		case <-p.done:
+			return nil, errPoolClosed
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for a RabbitMQ channel: %w", ctx.Err())
+		}
+
+		c, err := p.openChannel(ctx)
+		if err != nil {
+			<-p.open
+			return nil, err
+		}
+		return c, nil
+	}
+}
+
+// openChannel abre un canal nuevo. Si la conexión se cayó pero run todavía no
+// la descartó, espera la siguiente en vez de fallar.
+func (p *channelPool) openChannel(ctx context.Context) (*confirmChannel, error) {
+	for {
+		p.mu.Lock()
+		conn, ready, lost := p.conn, p.ready, p.lost
+		p.mu.Unlock()
+
+		if conn == nil {
+			select {
+			case <-ready:
+				continue
+			case <-p.done:
+				return nil, errPoolClosed
+			case <-ctx.Done():
+				return nil, fmt.Errorf("waiting for RabbitMQ connection: %w", ctx.Err())
+			}
+		}
+
+		ch, err := conn.Channel()
+		if err != nil {
+			if !conn.IsClosed() {
+				return nil, fmt.Errorf("opening channel: %w", err)
+			}
+			select {
+			case <-lost:
+				continue
+			case <-p.done:
+				return nil, errPoolClosed
+			case <-ctx.Done():
+				return nil, fmt.Errorf("waiting for RabbitMQ connection: %w", ctx.Err())
+			}
+		}
+		if err := ch.Confirm(false); err != nil {
+			ch.Close()
+			return nil, fmt.Errorf("enabling publisher confirms: %w", err)
+		}
+		return &confirmChannel{
+			ch:      ch,
+			returns: ch.NotifyReturn(make(chan amqp.Return, 1)),
+		}, nil
+	}
+}
+
+// Put devuelve un canal al pool. Los canales que fallaron se descartan para no
+// heredar confirmaciones o retornos pendientes en la siguiente publicación.
+func (p *channelPool) Put(c *confirmChannel, failed bool) {
+	if failed || c.ch.IsClosed() {
+		p.discard(c)
+		return
+	}
+	select {
+	case p.idle <- c:
+	default:
+		p.discard(c)
+	}
+}
+
+// discard cierra c y libera su lugar.
+func (p *channelPool) discard(c *confirmChannel) {
+	c.ch.Close()
+	<-p.open
+}
+
+func (p *channelPool) Close() {
+	p.once.Do(func() { close(p.done) })
+}
@@ -0,0 +1,120 @@
+// Package message define el formato JSON con el que los writers publican los
+// reportes de clima en Kafka y RabbitMQ.
+package message
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"servidor-api-go/internal/proto"
+)
+
+// WeatherMessage es el cuerpo de cada mensaje publicado en los brokers. Los
+// consumidores lo decodifican con una estructura equivalente.
+type WeatherMessage struct {
+	ID          string    `json:"id,omitempty"`
+	Description string    `json:"description"`
+	Country     string    `json:"country"`
+	Weather     Weather   `json:"weather"`
+	EventTime   time.Time `json:"event_time,omitzero"`
+	IngestedAt  time.Time `json:"ingested_at,omitzero"`
+	Source      string    `json:"source,omitempty"`
+}
+
+// FromRequest construye el mensaje a publicar a partir de la petición gRPC. Si
+// weather_type no está fijado usa el clima como texto del campo weather
+// (obsoleto), que todavía envían los clientes anteriores a weather_type.
+func FromRequest(tweet *proto.WeatherRequest) WeatherMessage {
+	msg := WeatherMessage{
+		ID:          tweet.GetId(),
+		Description: tweet.GetDescription(),
+		Country:     tweet.GetCountry(),
+		Weather:     Weather(tweet.GetWeatherType()),
+		Source:      tweet.GetSource(),
+	}
+	if msg.Weather == Weather(proto.Weather_WEATHER_UNSPECIFIED) {
+		value, _ := ParseWeather(strings.ToLower(strings.TrimSpace(tweet.GetWeather())))
+		msg.Weather = Weather(value)
+	}
+	if tweet.GetEventTime() != nil {
+		msg.EventTime = tweet.GetEventTime().AsTime()
+	}
+	if tweet.GetIngestedAt() != nil {
+		msg.IngestedAt = tweet.GetIngestedAt().AsTime()
+	}
+	return msg
+}
+
+// Marshal codifica el mensaje en JSON.
+func (m WeatherMessage) Marshal() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// weatherNames son los nombres de cada valor de proto.Weather en el JSON de
+// los clientes y de los brokers.
+var weatherNames = map[proto.Weather]string{
+	proto.Weather_WEATHER_LLUVIOSO: "lluvioso",
+	proto.Weather_WEATHER_NUBLOSO:  "nubloso",
+	proto.Weather_WEATHER_SOLEADO:  "soleado",
+}
+
+// WeatherNames devuelve los nombres JSON válidos, en el orden del enum.
+func WeatherNames() []string {
+	names := make([]string, 0, len(weatherNames))
+	for value := range len(proto.Weather_name) {
+		if name, ok := weatherNames[proto.Weather(value)]; ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// ParseWeather traduce el nombre JSON de un clima a proto.Weather.
+func ParseWeather(name string) (proto.Weather, bool) {
+	for value, candidate := range weatherNames {
+		if candidate == name {
+			return value, true
+		}
+	}
+	return proto.Weather_WEATHER_UNSPECIFIED, false
+}
+
+// Weather es el enum proto.Weather codificado en JSON con su nombre en
+// minúsculas ("lluvioso", "nubloso" o "soleado"). No admite
+// WEATHER_UNSPECIFIED ni valores desconocidos, así nunca llega a un broker un
+// reporte sin clima válido.
+type Weather proto.Weather
+
+func (w Weather) MarshalJSON() ([]byte, error) {
+	if proto.Weather(w) == proto.Weather_WEATHER_UNSPECIFIED {
+		return nil, fmt.Errorf("weather is not set")
+	}
+	name, ok := weatherNames[proto.Weather(w)]
+	if !ok {
+		return nil, fmt.Errorf("unknown weather %d", int32(w))
+	}
+	return json.Marshal(name)
+}
+
+func (w *Weather) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+	value, ok := ParseWeather(name)
+	if !ok {
+		return fmt.Errorf("unknown weather %q", name)
+	}
+	*w = Weather(value)
+	return nil
+}
+
+// String devuelve el nombre JSON, o el del enum si no tiene uno.
+func (w Weather) String() string {
+	if name, ok := weatherNames[proto.Weather(w)]; ok {
+		return name
+	}
+	return proto.Weather(w).String()
+}
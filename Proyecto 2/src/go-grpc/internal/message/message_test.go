@@ -0,0 +1,107 @@
+package message
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"servidor-api-go/internal/proto"
+)
+
+func TestWeatherJSON(t *testing.T) {
+	tests := []struct {
+		weather proto.Weather
+		want    string
+		wantErr bool
+	}{
+		{weather: proto.Weather_WEATHER_LLUVIOSO, want: `"lluvioso"`},
+		{weather: proto.Weather_WEATHER_NUBLOSO, want: `"nubloso"`},
+		{weather: proto.Weather_WEATHER_SOLEADO, want: `"soleado"`},
+		{weather: proto.Weather_WEATHER_UNSPECIFIED, wantErr: true},
+		{weather: proto.Weather(42), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.weather.String(), func(t *testing.T) {
+			got, err := json.Marshal(Weather(tt.weather))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Marshal(%v) error = %v, want error %v", tt.weather, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if string(got) != tt.want {
+				t.Errorf("Marshal(%v) = %s, want %s", tt.weather, got, tt.want)
+			}
+			var back Weather
+			if err := json.Unmarshal(got, &back); err != nil || proto.Weather(back) != tt.weather {
+				t.Errorf("Unmarshal(%s) = %v (%v), want %v", got, proto.Weather(back), err, tt.weather)
+			}
+		})
+	}
+}
+
+func TestWeatherUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    proto.Weather
+		wantErr bool
+	}{
+		{data: `"lluvioso"`, want: proto.Weather_WEATHER_LLUVIOSO},
+		{data: `"soleado"`, want: proto.Weather_WEATHER_SOLEADO},
+		// Los brokers siempre usan minúsculas; la normalización es del entrypoint
+		{data: `"Soleado"`, wantErr: true},
+		{data: `"WEATHER_SOLEADO"`, wantErr: true},
+		{data: `"granizo"`, wantErr: true},
+		{data: `""`, wantErr: true},
+		{data: `3`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			var got Weather
+			err := json.Unmarshal([]byte(tt.data), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal(%s) error = %v, want error %v", tt.data, err, tt.wantErr)
+			}
+			if proto.Weather(got) != tt.want {
+				t.Errorf("Unmarshal(%s) = %v, want %v", tt.data, proto.Weather(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	eventTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		tweet *proto.WeatherRequest
+		want  string
+	}{
+		{
+			name:  "weather_type",
+			tweet: &proto.WeatherRequest{Id: "1", Description: "sol", Country: "GT", WeatherType: proto.Weather_WEATHER_SOLEADO, EventTime: timestamppb.New(eventTime), Source: "grpc"},
+			want:  `{"id":"1","description":"sol","country":"GT","weather":"soleado","event_time":"2024-05-01T12:00:00Z","source":"grpc"}`,
+		},
+		{
+			name:  "legacy weather text",
+			tweet: &proto.WeatherRequest{Id: "2", Description: "lluvia", Country: "SV", Weather: " Lluvioso"},
+			want:  `{"id":"2","description":"lluvia","country":"SV","weather":"lluvioso"}`,
+		},
+		{
+			name:  "weather_type wins over the legacy text",
+			tweet: &proto.WeatherRequest{Id: "3", Description: "nubes", Country: "HN", WeatherType: proto.Weather_WEATHER_NUBLOSO, Weather: "soleado"},
+			want:  `{"id":"3","description":"nubes","country":"HN","weather":"nubloso"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromRequest(tt.tweet).Marshal()
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("FromRequest() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ package proto
 import (
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
 	unsafe "unsafe"
@@ -21,11 +22,81 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// Weather es el clima de un reporte. En el JSON de los clientes y de los
+// brokers se escribe en minúsculas ("lluvioso", "nubloso", "soleado"); la
+// traducción está en internal/message.
+type Weather int32
+
+const (
+	Weather_WEATHER_UNSPECIFIED Weather = 0
+	Weather_WEATHER_LLUVIOSO    Weather = 1
+	Weather_WEATHER_NUBLOSO     Weather = 2
+	Weather_WEATHER_SOLEADO     Weather = 3
+)
+
+// Enum value maps for Weather.
+var (
+	Weather_name = map[int32]string{
+		0: "WEATHER_UNSPECIFIED",
+		1: "WEATHER_LLUVIOSO",
+		2: "WEATHER_NUBLOSO",
+		3: "WEATHER_SOLEADO",
+	}
+	Weather_value = map[string]int32{
+		"WEATHER_UNSPECIFIED": 0,
+		"WEATHER_LLUVIOSO":    1,
+		"WEATHER_NUBLOSO":     2,
+		"WEATHER_SOLEADO":     3,
+	}
+)
+
+func (x Weather) Enum() *Weather {
+	p := new(Weather)
+	*p = x
+	return p
+}
+
+func (x Weather) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Weather) Descriptor() protoreflect.EnumDescriptor {
+	return file_internal_proto_weather_proto_enumTypes[0].Descriptor()
+}
+
+func (Weather) Type() protoreflect.EnumType {
+	return &file_internal_proto_weather_proto_enumTypes[0]
+}
+
+func (x Weather) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Weather.Descriptor instead.
+func (Weather) EnumDescriptor() ([]byte, []int) {
+	return file_internal_proto_weather_proto_rawDescGZIP(), []int{0}
+}
+
 type WeatherRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Description   string                 `protobuf:"bytes,1,opt,name=description,proto3" json:"description,omitempty"`
-	Country       string                 `protobuf:"bytes,2,opt,name=country,proto3" json:"country,omitempty"`
-	Weather       string                 `protobuf:"bytes,3,opt,name=weather,proto3" json:"weather,omitempty"`
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Description string                 `protobuf:"bytes,1,opt,name=description,proto3" json:"description,omitempty"`
+	Country     string                 `protobuf:"bytes,2,opt,name=country,proto3" json:"country,omitempty"`
+	// Clima como texto ("soleado"), el formato anterior a weather_type. El
+	// entrypoint lo sigue aceptando por una versión más y lo traduce a
+	// weather_type; después el campo 3 queda reservado. Los clientes nuevos
+	// deben usar weather_type.
+	//
+	// Deprecated: Marked as deprecated in internal/proto/weather.proto.
+	Weather string `protobuf:"bytes,3,opt,name=weather,proto3" json:"weather,omitempty"`
+	// Identificador único del reporte; el entrypoint lo genera si el cliente no lo envía.
+	Id string `protobuf:"bytes,4,opt,name=id,proto3" json:"id,omitempty"`
+	// Momento en que ocurrió el reporte; por defecto, cuando el entrypoint lo recibe.
+	EventTime *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=event_time,json=eventTime,proto3" json:"event_time,omitempty"`
+	// Momento en que el entrypoint aceptó el reporte.
+	IngestedAt *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=ingested_at,json=ingestedAt,proto3" json:"ingested_at,omitempty"`
+	// Origen del reporte (por ejemplo "http").
+	Source        string  `protobuf:"bytes,7,opt,name=source,proto3" json:"source,omitempty"`
+	WeatherType   Weather `protobuf:"varint,8,opt,name=weather_type,json=weatherType,proto3,enum=weather.Weather" json:"weather_type,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -74,6 +145,7 @@ func (x *WeatherRequest) GetCountry() string {
 	return ""
 }
 
+// Deprecated: Marked as deprecated in internal/proto/weather.proto.
 func (x *WeatherRequest) GetWeather() string {
 	if x != nil {
 		return x.Weather
@@ -81,10 +153,53 @@ func (x *WeatherRequest) GetWeather() string {
 	return ""
 }
 
+func (x *WeatherRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *WeatherRequest) GetEventTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.EventTime
+	}
+	return nil
+}
+
+func (x *WeatherRequest) GetIngestedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.IngestedAt
+	}
+	return nil
+}
+
+func (x *WeatherRequest) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *WeatherRequest) GetWeatherType() Weather {
+	if x != nil {
+		return x.WeatherType
+	}
+	return Weather_WEATHER_UNSPECIFIED
+}
+
+// Si la publicación falla el writer responde success=false con un código
+// estable en code (por ejemplo "DELIVERY_FAILED" o "NACKED") en lugar de un
+// error gRPC, así el entrypoint puede informarlo por backend. El entrypoint
+// usa code con success=true para avisar que el reporte quedó "PARTIAL"
+// (quorum alcanzado, faltan copias) o "SPOOLED" (pendiente de reenvío).
 type WeatherResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
-	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Success bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Code    string                 `protobuf:"bytes,3,opt,name=code,proto3" json:"code,omitempty"`
+	// Identificador del reporte; lo completa el entrypoint.
+	Id            string `protobuf:"bytes,4,opt,name=id,proto3" json:"id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -133,21 +248,344 @@ func (x *WeatherResponse) GetMessage() string {
 	return ""
 }
 
+func (x *WeatherResponse) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *WeatherResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type WeatherBatchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Requests      []*WeatherRequest      `protobuf:"bytes,1,rep,name=requests,proto3" json:"requests,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WeatherBatchRequest) Reset() {
+	*x = WeatherBatchRequest{}
+	mi := &file_internal_proto_weather_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WeatherBatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WeatherBatchRequest) ProtoMessage() {}
+
+func (x *WeatherBatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_weather_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WeatherBatchRequest.ProtoReflect.Descriptor instead.
+func (*WeatherBatchRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_weather_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *WeatherBatchRequest) GetRequests() []*WeatherRequest {
+	if x != nil {
+		return x.Requests
+	}
+	return nil
+}
+
+// Resultado de publicar un reporte de un lote o de un flujo.
+type WeatherResult struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Index   int32                  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Id      string                 `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	Success bool                   `protobuf:"varint,3,opt,name=success,proto3" json:"success,omitempty"`
+	Message string                 `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+	// Código estable del fallo, igual que WeatherResponse.code.
+	Code          string `protobuf:"bytes,5,opt,name=code,proto3" json:"code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WeatherResult) Reset() {
+	*x = WeatherResult{}
+	mi := &file_internal_proto_weather_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WeatherResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WeatherResult) ProtoMessage() {}
+
+func (x *WeatherResult) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_weather_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WeatherResult.ProtoReflect.Descriptor instead.
+func (*WeatherResult) Descriptor() ([]byte, []int) {
+	return file_internal_proto_weather_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *WeatherResult) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *WeatherResult) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *WeatherResult) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *WeatherResult) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *WeatherResult) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+type WeatherBatchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Results       []*WeatherResult       `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	Published     int32                  `protobuf:"varint,2,opt,name=published,proto3" json:"published,omitempty"`
+	Failed        int32                  `protobuf:"varint,3,opt,name=failed,proto3" json:"failed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WeatherBatchResponse) Reset() {
+	*x = WeatherBatchResponse{}
+	mi := &file_internal_proto_weather_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WeatherBatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WeatherBatchResponse) ProtoMessage() {}
+
+func (x *WeatherBatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_weather_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WeatherBatchResponse.ProtoReflect.Descriptor instead.
+func (*WeatherBatchResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_weather_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *WeatherBatchResponse) GetResults() []*WeatherResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+func (x *WeatherBatchResponse) GetPublished() int32 {
+	if x != nil {
+		return x.Published
+	}
+	return 0
+}
+
+func (x *WeatherBatchResponse) GetFailed() int32 {
+	if x != nil {
+		return x.Failed
+	}
+	return 0
+}
+
+type DescribeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DescribeRequest) Reset() {
+	*x = DescribeRequest{}
+	mi := &file_internal_proto_weather_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DescribeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DescribeRequest) ProtoMessage() {}
+
+func (x *DescribeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_weather_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DescribeRequest.ProtoReflect.Descriptor instead.
+func (*DescribeRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_weather_proto_rawDescGZIP(), []int{5}
+}
+
+type DescribeResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Nombre del backend, por ejemplo "kafka" o "rabbitmq".
+	Backend string `protobuf:"bytes,1,opt,name=backend,proto3" json:"backend,omitempty"`
+	// Topic o cola donde se publican los reportes.
+	Destination   string `protobuf:"bytes,2,opt,name=destination,proto3" json:"destination,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DescribeResponse) Reset() {
+	*x = DescribeResponse{}
+	mi := &file_internal_proto_weather_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DescribeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DescribeResponse) ProtoMessage() {}
+
+func (x *DescribeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_weather_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DescribeResponse.ProtoReflect.Descriptor instead.
+func (*DescribeResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_weather_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *DescribeResponse) GetBackend() string {
+	if x != nil {
+		return x.Backend
+	}
+	return ""
+}
+
+func (x *DescribeResponse) GetDestination() string {
+	if x != nil {
+		return x.Destination
+	}
+	return ""
+}
+
 var File_internal_proto_weather_proto protoreflect.FileDescriptor
 
 const file_internal_proto_weather_proto_rawDesc = "" +
 	"\n" +
-	"\x1cinternal/proto/weather.proto\x12\aweather\"f\n" +
+	"\x1cinternal/proto/weather.proto\x12\aweather\x1a\x1fgoogle/protobuf/timestamp.proto\"\xbf\x02\n" +
 	"\x0eWeatherRequest\x12 \n" +
 	"\vdescription\x18\x01 \x01(\tR\vdescription\x12\x18\n" +
-	"\acountry\x18\x02 \x01(\tR\acountry\x12\x18\n" +
-	"\aweather\x18\x03 \x01(\tR\aweather\"E\n" +
+	"\acountry\x18\x02 \x01(\tR\acountry\x12\x1c\n" +
+	"\aweather\x18\x03 \x01(\tB\x02\x18\x01R\aweather\x12\x0e\n" +
+	"\x02id\x18\x04 \x01(\tR\x02id\x129\n" +
+	"\n" +
+	"event_time\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\teventTime\x12;\n" +
+	"\vingested_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"ingestedAt\x12\x16\n" +
+	"\x06source\x18\a \x01(\tR\x06source\x123\n" +
+	"\fweather_type\x18\b \x01(\x0e2\x10.weather.WeatherR\vweatherType\"i\n" +
 	"\x0fWeatherResponse\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\tR\amessage2\x9d\x01\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x12\n" +
+	"\x04code\x18\x03 \x01(\tR\x04code\x12\x0e\n" +
+	"\x02id\x18\x04 \x01(\tR\x02id\"J\n" +
+	"\x13WeatherBatchRequest\x123\n" +
+	"\brequests\x18\x01 \x03(\v2\x17.weather.WeatherRequestR\brequests\"}\n" +
+	"\rWeatherResult\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\x12\x18\n" +
+	"\asuccess\x18\x03 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x04 \x01(\tR\amessage\x12\x12\n" +
+	"\x04code\x18\x05 \x01(\tR\x04code\"~\n" +
+	"\x14WeatherBatchResponse\x120\n" +
+	"\aresults\x18\x01 \x03(\v2\x16.weather.WeatherResultR\aresults\x12\x1c\n" +
+	"\tpublished\x18\x02 \x01(\x05R\tpublished\x12\x16\n" +
+	"\x06failed\x18\x03 \x01(\x05R\x06failed\"\x11\n" +
+	"\x0fDescribeRequest\"N\n" +
+	"\x10DescribeResponse\x12\x18\n" +
+	"\abackend\x18\x01 \x01(\tR\abackend\x12 \n" +
+	"\vdestination\x18\x02 \x01(\tR\vdestination*b\n" +
+	"\aWeather\x12\x17\n" +
+	"\x13WEATHER_UNSPECIFIED\x10\x00\x12\x14\n" +
+	"\x10WEATHER_LLUVIOSO\x10\x01\x12\x13\n" +
+	"\x0fWEATHER_NUBLOSO\x10\x02\x12\x13\n" +
+	"\x0fWEATHER_SOLEADO\x10\x032\xa9\x02\n" +
+	"\x10PublisherService\x12<\n" +
+	"\aPublish\x12\x17.weather.WeatherRequest\x1a\x18.weather.WeatherResponse\x12K\n" +
+	"\fPublishBatch\x12\x1c.weather.WeatherBatchRequest\x1a\x1d.weather.WeatherBatchResponse\x12I\n" +
+	"\rStreamPublish\x12\x17.weather.WeatherRequest\x1a\x1d.weather.WeatherBatchResponse(\x01\x12?\n" +
+	"\bDescribe\x12\x18.weather.DescribeRequest\x1a\x19.weather.DescribeResponse2\xc8\x02\n" +
 	"\x0eWeatherService\x12F\n" +
 	"\x11PublishToRabbitMQ\x12\x17.weather.WeatherRequest\x1a\x18.weather.WeatherResponse\x12C\n" +
-	"\x0ePublishToKafka\x12\x17.weather.WeatherRequest\x1a\x18.weather.WeatherResponseB Z\x1eservidor-api-go/internal/protob\x06proto3"
+	"\x0ePublishToKafka\x12\x17.weather.WeatherRequest\x1a\x18.weather.WeatherResponse\x12R\n" +
+	"\x13PublishBatchToKafka\x12\x1c.weather.WeatherBatchRequest\x1a\x1d.weather.WeatherBatchResponse\x12U\n" +
+	"\x16PublishBatchToRabbitMQ\x12\x1c.weather.WeatherBatchRequest\x1a\x1d.weather.WeatherBatchResponseB Z\x1eservidor-api-go/internal/protob\x06proto3"
 
 var (
 	file_internal_proto_weather_proto_rawDescOnce sync.Once
@@ -161,21 +599,46 @@ func file_internal_proto_weather_proto_rawDescGZIP() []byte {
 	return file_internal_proto_weather_proto_rawDescData
 }
 
-var file_internal_proto_weather_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_internal_proto_weather_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_internal_proto_weather_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
 var file_internal_proto_weather_proto_goTypes = []any{
-	(*WeatherRequest)(nil),  // 0: weather.WeatherRequest
-	(*WeatherResponse)(nil), // 1: weather.WeatherResponse
+	(Weather)(0),                  // 0: weather.Weather
+	(*WeatherRequest)(nil),        // 1: weather.WeatherRequest
+	(*WeatherResponse)(nil),       // 2: weather.WeatherResponse
+	(*WeatherBatchRequest)(nil),   // 3: weather.WeatherBatchRequest
+	(*WeatherResult)(nil),         // 4: weather.WeatherResult
+	(*WeatherBatchResponse)(nil),  // 5: weather.WeatherBatchResponse
+	(*DescribeRequest)(nil),       // 6: weather.DescribeRequest
+	(*DescribeResponse)(nil),      // 7: weather.DescribeResponse
+	(*timestamppb.Timestamp)(nil), // 8: google.protobuf.Timestamp
 }
 var file_internal_proto_weather_proto_depIdxs = []int32{
-	0, // 0: weather.WeatherService.PublishToRabbitMQ:input_type -> weather.WeatherRequest
-	0, // 1: weather.WeatherService.PublishToKafka:input_type -> weather.WeatherRequest
-	1, // 2: weather.WeatherService.PublishToRabbitMQ:output_type -> weather.WeatherResponse
-	1, // 3: weather.WeatherService.PublishToKafka:output_type -> weather.WeatherResponse
-	2, // [2:4] is the sub-list for method output_type
-	0, // [0:2] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	8,  // 0: weather.WeatherRequest.event_time:type_name -> google.protobuf.Timestamp
+	8,  // 1: weather.WeatherRequest.ingested_at:type_name -> google.protobuf.Timestamp
+	0,  // 2: weather.WeatherRequest.weather_type:type_name -> weather.Weather
+	1,  // 3: weather.WeatherBatchRequest.requests:type_name -> weather.WeatherRequest
+	4,  // 4: weather.WeatherBatchResponse.results:type_name -> weather.WeatherResult
+	1,  // 5: weather.PublisherService.Publish:input_type -> weather.WeatherRequest
+	3,  // 6: weather.PublisherService.PublishBatch:input_type -> weather.WeatherBatchRequest
+	1,  // 7: weather.PublisherService.StreamPublish:input_type -> weather.WeatherRequest
+	6,  // 8: weather.PublisherService.Describe:input_type -> weather.DescribeRequest
+	1,  // 9: weather.WeatherService.PublishToRabbitMQ:input_type -> weather.WeatherRequest
+	1,  // 10: weather.WeatherService.PublishToKafka:input_type -> weather.WeatherRequest
+	3,  // 11: weather.WeatherService.PublishBatchToKafka:input_type -> weather.WeatherBatchRequest
+	3,  // 12: weather.WeatherService.PublishBatchToRabbitMQ:input_type -> weather.WeatherBatchRequest
+	2,  // 13: weather.PublisherService.Publish:output_type -> weather.WeatherResponse
+	5,  // 14: weather.PublisherService.PublishBatch:output_type -> weather.WeatherBatchResponse
+	5,  // 15: weather.PublisherService.StreamPublish:output_type -> weather.WeatherBatchResponse
+	7,  // 16: weather.PublisherService.Describe:output_type -> weather.DescribeResponse
+	2,  // 17: weather.WeatherService.PublishToRabbitMQ:output_type -> weather.WeatherResponse
+	2,  // 18: weather.WeatherService.PublishToKafka:output_type -> weather.WeatherResponse
+	5,  // 19: weather.WeatherService.PublishBatchToKafka:output_type -> weather.WeatherBatchResponse
+	5,  // 20: weather.WeatherService.PublishBatchToRabbitMQ:output_type -> weather.WeatherBatchResponse
+	13, // [13:21] is the sub-list for method output_type
+	5,  // [5:13] is the sub-list for method input_type
+	5,  // [5:5] is the sub-list for extension type_name
+	5,  // [5:5] is the sub-list for extension extendee
+	0,  // [0:5] is the sub-list for field type_name
 }
 
 func init() { file_internal_proto_weather_proto_init() }
@@ -188,13 +651,14 @@ func file_internal_proto_weather_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_internal_proto_weather_proto_rawDesc), len(file_internal_proto_weather_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   2,
+			NumEnums:      1,
+			NumMessages:   7,
 			NumExtensions: 0,
-			NumServices:   1,
+			NumServices:   2,
 		},
 		GoTypes:           file_internal_proto_weather_proto_goTypes,
 		DependencyIndexes: file_internal_proto_weather_proto_depIdxs,
+		EnumInfos:         file_internal_proto_weather_proto_enumTypes,
 		MessageInfos:      file_internal_proto_weather_proto_msgTypes,
 	}.Build()
 	File_internal_proto_weather_proto = out.File
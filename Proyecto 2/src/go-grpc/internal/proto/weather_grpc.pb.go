@@ -19,16 +19,251 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	WeatherService_PublishToRabbitMQ_FullMethodName = "/weather.WeatherService/PublishToRabbitMQ"
-	WeatherService_PublishToKafka_FullMethodName    = "/weather.WeatherService/PublishToKafka"
+	PublisherService_Publish_FullMethodName       = "/weather.PublisherService/Publish"
+	PublisherService_PublishBatch_FullMethodName  = "/weather.PublisherService/PublishBatch"
+	PublisherService_StreamPublish_FullMethodName = "/weather.PublisherService/StreamPublish"
+	PublisherService_Describe_FullMethodName      = "/weather.PublisherService/Describe"
+)
+
+// PublisherServiceClient is the client API for PublisherService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// PublisherService es la interfaz común de los writers: cada writer publica en
+// el broker que representa y lo informa en Describe. El entrypoint habla con
+// cualquier cantidad de writers a través de este servicio.
+type PublisherServiceClient interface {
+	Publish(ctx context.Context, in *WeatherRequest, opts ...grpc.CallOption) (*WeatherResponse, error)
+	// Publica varios reportes en una sola llamada; la respuesta trae un resultado por reporte.
+	PublishBatch(ctx context.Context, in *WeatherBatchRequest, opts ...grpc.CallOption) (*WeatherBatchResponse, error)
+	// El cliente envía un flujo de reportes; al cerrarlo recibe la confirmación de cada uno
+	// en el orden en que los envió.
+	StreamPublish(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[WeatherRequest, WeatherBatchResponse], error)
+	// Describe informa qué backend está detrás del writer.
+	Describe(ctx context.Context, in *DescribeRequest, opts ...grpc.CallOption) (*DescribeResponse, error)
+}
+
+type publisherServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPublisherServiceClient(cc grpc.ClientConnInterface) PublisherServiceClient {
+	return &publisherServiceClient{cc}
+}
+
+func (c *publisherServiceClient) Publish(ctx context.Context, in *WeatherRequest, opts ...grpc.CallOption) (*WeatherResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(WeatherResponse)
+	err := c.cc.Invoke(ctx, PublisherService_Publish_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *publisherServiceClient) PublishBatch(ctx context.Context, in *WeatherBatchRequest, opts ...grpc.CallOption) (*WeatherBatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(WeatherBatchResponse)
+	err := c.cc.Invoke(ctx, PublisherService_PublishBatch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *publisherServiceClient) StreamPublish(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[WeatherRequest, WeatherBatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PublisherService_ServiceDesc.Streams[0], PublisherService_StreamPublish_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WeatherRequest, WeatherBatchResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PublisherService_StreamPublishClient = grpc.ClientStreamingClient[WeatherRequest, WeatherBatchResponse]
+
+func (c *publisherServiceClient) Describe(ctx context.Context, in *DescribeRequest, opts ...grpc.CallOption) (*DescribeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DescribeResponse)
+	err := c.cc.Invoke(ctx, PublisherService_Describe_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PublisherServiceServer is the server API for PublisherService service.
+// All implementations must embed UnimplementedPublisherServiceServer
+// for forward compatibility.
+//
+// PublisherService es la interfaz común de los writers: cada writer publica en
+// el broker que representa y lo informa en Describe. El entrypoint habla con
+// cualquier cantidad de writers a través de este servicio.
+type PublisherServiceServer interface {
+	Publish(context.Context, *WeatherRequest) (*WeatherResponse, error)
+	// Publica varios reportes en una sola llamada; la respuesta trae un resultado por reporte.
+	PublishBatch(context.Context, *WeatherBatchRequest) (*WeatherBatchResponse, error)
+	// El cliente envía un flujo de reportes; al cerrarlo recibe la confirmación de cada uno
+	// en el orden en que los envió.
+	StreamPublish(grpc.ClientStreamingServer[WeatherRequest, WeatherBatchResponse]) error
+	// Describe informa qué backend está detrás del writer.
+	Describe(context.Context, *DescribeRequest) (*DescribeResponse, error)
+	mustEmbedUnimplementedPublisherServiceServer()
+}
+
+// UnimplementedPublisherServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPublisherServiceServer struct{}
+
+func (UnimplementedPublisherServiceServer) Publish(context.Context, *WeatherRequest) (*WeatherResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Publish not implemented")
+}
+func (UnimplementedPublisherServiceServer) PublishBatch(context.Context, *WeatherBatchRequest) (*WeatherBatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PublishBatch not implemented")
+}
+func (UnimplementedPublisherServiceServer) StreamPublish(grpc.ClientStreamingServer[WeatherRequest, WeatherBatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamPublish not implemented")
+}
+func (UnimplementedPublisherServiceServer) Describe(context.Context, *DescribeRequest) (*DescribeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Describe not implemented")
+}
+func (UnimplementedPublisherServiceServer) mustEmbedUnimplementedPublisherServiceServer() {}
+func (UnimplementedPublisherServiceServer) testEmbeddedByValue()                          {}
+
+// UnsafePublisherServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PublisherServiceServer will
+// result in compilation errors.
+type UnsafePublisherServiceServer interface {
+	mustEmbedUnimplementedPublisherServiceServer()
+}
+
+func RegisterPublisherServiceServer(s grpc.ServiceRegistrar, srv PublisherServiceServer) {
+	// If the following call pancis, it indicates UnimplementedPublisherServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&PublisherService_ServiceDesc, srv)
+}
+
+func _PublisherService_Publish_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WeatherRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PublisherServiceServer).Publish(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PublisherService_Publish_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PublisherServiceServer).Publish(ctx, req.(*WeatherRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PublisherService_PublishBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WeatherBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PublisherServiceServer).PublishBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PublisherService_PublishBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PublisherServiceServer).PublishBatch(ctx, req.(*WeatherBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PublisherService_StreamPublish_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(PublisherServiceServer).StreamPublish(&grpc.GenericServerStream[WeatherRequest, WeatherBatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PublisherService_StreamPublishServer = grpc.ClientStreamingServer[WeatherRequest, WeatherBatchResponse]
+
+func _PublisherService_Describe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DescribeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PublisherServiceServer).Describe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PublisherService_Describe_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PublisherServiceServer).Describe(ctx, req.(*DescribeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// PublisherService_ServiceDesc is the grpc.ServiceDesc for PublisherService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PublisherService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "weather.PublisherService",
+	HandlerType: (*PublisherServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Publish",
+			Handler:    _PublisherService_Publish_Handler,
+		},
+		{
+			MethodName: "PublishBatch",
+			Handler:    _PublisherService_PublishBatch_Handler,
+		},
+		{
+			MethodName: "Describe",
+			Handler:    _PublisherService_Describe_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamPublish",
+			Handler:       _PublisherService_StreamPublish_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "internal/proto/weather.proto",
+}
+
+const (
+	WeatherService_PublishToRabbitMQ_FullMethodName      = "/weather.WeatherService/PublishToRabbitMQ"
+	WeatherService_PublishToKafka_FullMethodName         = "/weather.WeatherService/PublishToKafka"
+	WeatherService_PublishBatchToKafka_FullMethodName    = "/weather.WeatherService/PublishBatchToKafka"
+	WeatherService_PublishBatchToRabbitMQ_FullMethodName = "/weather.WeatherService/PublishBatchToRabbitMQ"
 )
 
 // WeatherServiceClient is the client API for WeatherService service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// WeatherService es la interfaz original, con un método por broker. Los writers
+// la siguen exponiendo por compatibilidad; llamar al método del otro broker
+// devuelve FAILED_PRECONDITION.
 type WeatherServiceClient interface {
 	PublishToRabbitMQ(ctx context.Context, in *WeatherRequest, opts ...grpc.CallOption) (*WeatherResponse, error)
 	PublishToKafka(ctx context.Context, in *WeatherRequest, opts ...grpc.CallOption) (*WeatherResponse, error)
+	// Publican varios reportes en una sola llamada; la respuesta trae un resultado por reporte.
+	PublishBatchToKafka(ctx context.Context, in *WeatherBatchRequest, opts ...grpc.CallOption) (*WeatherBatchResponse, error)
+	PublishBatchToRabbitMQ(ctx context.Context, in *WeatherBatchRequest, opts ...grpc.CallOption) (*WeatherBatchResponse, error)
 }
 
 type weatherServiceClient struct {
@@ -59,12 +294,39 @@ func (c *weatherServiceClient) PublishToKafka(ctx context.Context, in *WeatherRe
 	return out, nil
 }
 
+func (c *weatherServiceClient) PublishBatchToKafka(ctx context.Context, in *WeatherBatchRequest, opts ...grpc.CallOption) (*WeatherBatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(WeatherBatchResponse)
+	err := c.cc.Invoke(ctx, WeatherService_PublishBatchToKafka_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weatherServiceClient) PublishBatchToRabbitMQ(ctx context.Context, in *WeatherBatchRequest, opts ...grpc.CallOption) (*WeatherBatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(WeatherBatchResponse)
+	err := c.cc.Invoke(ctx, WeatherService_PublishBatchToRabbitMQ_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // WeatherServiceServer is the server API for WeatherService service.
 // All implementations must embed UnimplementedWeatherServiceServer
 // for forward compatibility.
+//
+// WeatherService es la interfaz original, con un método por broker. Los writers
+// la siguen exponiendo por compatibilidad; llamar al método del otro broker
+// devuelve FAILED_PRECONDITION.
 type WeatherServiceServer interface {
 	PublishToRabbitMQ(context.Context, *WeatherRequest) (*WeatherResponse, error)
 	PublishToKafka(context.Context, *WeatherRequest) (*WeatherResponse, error)
+	// Publican varios reportes en una sola llamada; la respuesta trae un resultado por reporte.
+	PublishBatchToKafka(context.Context, *WeatherBatchRequest) (*WeatherBatchResponse, error)
+	PublishBatchToRabbitMQ(context.Context, *WeatherBatchRequest) (*WeatherBatchResponse, error)
 	mustEmbedUnimplementedWeatherServiceServer()
 }
 
@@ -81,6 +343,12 @@ func (UnimplementedWeatherServiceServer) PublishToRabbitMQ(context.Context, *Wea
 func (UnimplementedWeatherServiceServer) PublishToKafka(context.Context, *WeatherRequest) (*WeatherResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method PublishToKafka not implemented")
 }
+func (UnimplementedWeatherServiceServer) PublishBatchToKafka(context.Context, *WeatherBatchRequest) (*WeatherBatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PublishBatchToKafka not implemented")
+}
+func (UnimplementedWeatherServiceServer) PublishBatchToRabbitMQ(context.Context, *WeatherBatchRequest) (*WeatherBatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PublishBatchToRabbitMQ not implemented")
+}
 func (UnimplementedWeatherServiceServer) mustEmbedUnimplementedWeatherServiceServer() {}
 func (UnimplementedWeatherServiceServer) testEmbeddedByValue()                        {}
 
@@ -138,6 +406,42 @@ func _WeatherService_PublishToKafka_Handler(srv interface{}, ctx context.Context
 	return interceptor(ctx, in, info, handler)
 }
 
+func _WeatherService_PublishBatchToKafka_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WeatherBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeatherServiceServer).PublishBatchToKafka(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WeatherService_PublishBatchToKafka_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeatherServiceServer).PublishBatchToKafka(ctx, req.(*WeatherBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WeatherService_PublishBatchToRabbitMQ_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WeatherBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeatherServiceServer).PublishBatchToRabbitMQ(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WeatherService_PublishBatchToRabbitMQ_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeatherServiceServer).PublishBatchToRabbitMQ(ctx, req.(*WeatherBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // WeatherService_ServiceDesc is the grpc.ServiceDesc for WeatherService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -153,6 +457,14 @@ var WeatherService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "PublishToKafka",
 			Handler:    _WeatherService_PublishToKafka_Handler,
 		},
+		{
+			MethodName: "PublishBatchToKafka",
+			Handler:    _WeatherService_PublishBatchToKafka_Handler,
+		},
+		{
+			MethodName: "PublishBatchToRabbitMQ",
+			Handler:    _WeatherService_PublishBatchToRabbitMQ_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "internal/proto/weather.proto",
@@ -0,0 +1,178 @@
+// Package topology declara la topología de RabbitMQ que comparten el writer
+// (cmd/rabbitmq-writer) y el consumidor (consumers/rabbitmq-consumer), que
+// importa este paquete del módulo go-grpc.
+// Ambos la declaran al conectarse con la misma configuración; RabbitMQ
+// rechaza (PRECONDITION_FAILED) volver a declarar una cola con otros
+// argumentos, así que deben usar las mismas variables de entorno.
+//
+// Los mensajes se publican en la cola principal a través del exchange por
+// defecto. Ningún mensaje se pierde ni circula sin fin:
+//
+//   - los que el consumidor rechaza sin reencolar (los que no se pueden
+//     decodificar) y, si se fija MessageTTL, los que pasan ese tiempo en la
+//     cola principal sin consumirse van al exchange de mensajes muertos
+//     (<cola>.dlx) y quedan en la DLQ (<cola>.dlq);
+//   - los que los sinks no aceptaron, un fallo pasajero, el consumidor los
+//     publica en el exchange de reintentos (<cola>.retry), que los deja en la
+//     cola de reintentos hasta que pasa RetryDelay y vuelven a la cola
+//     principal. El header x-retries cuenta los reintentos; pasados
+//     MaxRetries el consumidor los rechaza.
+//
+// Una cola declarada por una versión anterior, sin argumentos, hay que
+// vaciarla y borrarla una vez antes de desplegar esta topología; los pasos
+// están en infra/gke/rabbitmq-consumer-deployment.yaml.
+package topology
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Queue es la cola donde se publican los reportes.
+const Queue = "weather-tweets"
+
+// Headers de los mensajes reintentados.
+const (
+	HeaderRetries   = "x-retries"    // reintentos hechos
+	HeaderLastError = "x-last-error" // error del último intento
+)
+
+// Config es la configuración de la topología.
+type Config struct {
+	Queue      string
+	MessageTTL time.Duration // 0 para no expirar los mensajes de la cola principal
+	RetryDelay time.Duration
+	MaxRetries int
+}
+
+// ConfigFromEnv lee RABBITMQ_MESSAGE_TTL, RABBITMQ_RETRY_DELAY y
+// RABBITMQ_MAX_RETRIES; termina el proceso si alguna no es válida. Por
+// defecto los mensajes de la cola principal no expiran: un TTL manda a la
+// DLQ todo lo pendiente más viejo que él, así que hay que pedirlo.
+func ConfigFromEnv() Config {
+	return Config{
+		Queue:      Queue,
+		MessageTTL: envDuration("RABBITMQ_MESSAGE_TTL", 0),
+		RetryDelay: envDuration("RABBITMQ_RETRY_DELAY", 10*time.Second),
+		MaxRetries: envInt("RABBITMQ_MAX_RETRIES", 3),
+	}
+}
+
+// DeadLetterExchange y DeadLetterQueue reciben los mensajes apartados.
+func (c Config) DeadLetterExchange() string { return c.Queue + ".dlx" }
+func (c Config) DeadLetterQueue() string    { return c.Queue + ".dlq" }
+
+// RetryExchange y RetryQueue retienen los mensajes a reintentar.
+func (c Config) RetryExchange() string { return c.Queue + ".retry" }
+func (c Config) RetryQueue() string    { return c.Queue + ".retry" }
+
+// Declare declara los exchanges y las colas y devuelve la cola principal.
+func Declare(ch *amqp.Channel, c Config) (amqp.Queue, error) {
+	for _, exchange := range []string{c.DeadLetterExchange(), c.RetryExchange()} {
+		if err := ch.ExchangeDeclare(exchange, amqp.ExchangeDirect, true, false, false, false, nil); err != nil {
+			return amqp.Queue{}, fmt.Errorf("declare exchange %s: %w", exchange, err)
+		}
+	}
+
+	// Los mensajes muertos conservan su routing key, que es el nombre de la cola
+	if err := declareBound(ch, c.DeadLetterQueue(), c.DeadLetterExchange(), c.Queue, nil); err != nil {
+		return amqp.Queue{}, err
+	}
+	if err := declareBound(ch, c.RetryQueue(), c.RetryExchange(), c.Queue, amqp.Table{
+		"x-message-ttl":             c.RetryDelay.Milliseconds(),
+		"x-dead-letter-exchange":    "", // el exchange por defecto los devuelve a la cola principal
+		"x-dead-letter-routing-key": c.Queue,
+	}); err != nil {
+		return amqp.Queue{}, err
+	}
+
+	args := amqp.Table{"x-dead-letter-exchange": c.DeadLetterExchange()}
+	if c.MessageTTL > 0 {
+		args["x-message-ttl"] = c.MessageTTL.Milliseconds()
+	}
+	q, err := ch.QueueDeclare(
+		c.Queue, // name
+		true,    // durable
+		false,   // delete when unused
+		false,   // exclusive
+		false,   // no-wait
+		args,    // arguments
+	)
+	if err != nil {
+		return amqp.Queue{}, fmt.Errorf("declare queue %s: %w", c.Queue, err)
+	}
+	return q, nil
+}
+
+// declareBound declara una cola durable y la enlaza a exchange con key.
+func declareBound(ch *amqp.Channel, queue, exchange, key string, args amqp.Table) error {
+	if _, err := ch.QueueDeclare(queue, true, false, false, false, args); err != nil {
+		return fmt.Errorf("declare queue %s: %w", queue, err)
+	}
+	if err := ch.QueueBind(queue, key, exchange, false, nil); err != nil {
+		return fmt.Errorf("bind queue %s to %s: %w", queue, exchange, err)
+	}
+	return nil
+}
+
+// Retries devuelve cuántas veces se reintentó un mensaje.
+func Retries(headers amqp.Table) int {
+	switch n := headers[HeaderRetries].(type) {
+	case int32:
+		return int(n)
+	case int64:
+		return int(n)
+	case int:
+		return n
+	}
+	return 0
+}
+
+// RetryPublishing construye el mensaje que reintenta d: el mismo cuerpo y
+// propiedades, con el contador de reintentos aumentado y el error del intento.
+func RetryPublishing(d amqp.Delivery, cause error) amqp.Publishing {
+	headers := amqp.Table{}
+	for key, value := range d.Headers {
+		headers[key] = value
+	}
+	headers[HeaderRetries] = int32(Retries(d.Headers) + 1)
+	headers[HeaderLastError] = cause.Error()
+
+	return amqp.Publishing{
+		Headers:      headers,
+		ContentType:  d.ContentType,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    d.MessageId,
+		Timestamp:    d.Timestamp,
+		Body:         d.Body,
+	}
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		log.Fatalf("Invalid %s: %q", key, value)
+	}
+	return d
+}
+
+func envInt(key string, def int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Fatalf("Invalid %s: %q", key, value)
+	}
+	return n
+}
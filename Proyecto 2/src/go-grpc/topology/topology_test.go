@@ -0,0 +1,112 @@
+package topology
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers amqp.Table
+		want    int
+	}{
+		{name: "no headers", want: 0},
+		{name: "int32", headers: amqp.Table{HeaderRetries: int32(2)}, want: 2},
+		{name: "int64", headers: amqp.Table{HeaderRetries: int64(3)}, want: 3},
+		{name: "int", headers: amqp.Table{HeaderRetries: 4}, want: 4},
+		{name: "unexpected type", headers: amqp.Table{HeaderRetries: "5"}, want: 0},
+		{name: "other headers", headers: amqp.Table{"x-death": int32(7)}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Retries(tt.headers); got != tt.want {
+				t.Errorf("Retries(%v) = %d, want %d", tt.headers, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPublishing(t *testing.T) {
+	timestamp := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		headers     amqp.Table
+		wantHeaders amqp.Table
+	}{
+		{
+			name:        "first retry",
+			wantHeaders: amqp.Table{HeaderRetries: int32(1), HeaderLastError: "sink unavailable"},
+		},
+		{
+			name:        "counter grows and other headers are kept",
+			headers:     amqp.Table{HeaderRetries: int32(2), HeaderLastError: "old", "trace-id": "abc"},
+			wantHeaders: amqp.Table{HeaderRetries: int32(3), HeaderLastError: "sink unavailable", "trace-id": "abc"},
+		},
+		{
+			name:        "counter from a 64-bit header",
+			headers:     amqp.Table{HeaderRetries: int64(1)},
+			wantHeaders: amqp.Table{HeaderRetries: int32(2), HeaderLastError: "sink unavailable"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := amqp.Delivery{
+				Headers:     tt.headers,
+				ContentType: "application/json",
+				MessageId:   "m-1",
+				Timestamp:   timestamp,
+				Body:        []byte(`{"id":"1"}`),
+			}
+			original := len(d.Headers)
+
+			got := RetryPublishing(d, errors.New("sink unavailable"))
+			want := amqp.Publishing{
+				Headers:      tt.wantHeaders,
+				ContentType:  "application/json",
+				DeliveryMode: amqp.Persistent,
+				MessageId:    "m-1",
+				Timestamp:    timestamp,
+				Body:         []byte(`{"id":"1"}`),
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("RetryPublishing() = %+v, want %+v", got, want)
+			}
+			if len(d.Headers) != original {
+				t.Errorf("RetryPublishing modified the delivery headers: %v", d.Headers)
+			}
+		})
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want Config
+	}{
+		{
+			name: "defaults do not expire messages",
+			want: Config{Queue: Queue, RetryDelay: 10 * time.Second, MaxRetries: 3},
+		},
+		{
+			name: "message TTL is opt-in",
+			env:  map[string]string{"RABBITMQ_MESSAGE_TTL": "24h", "RABBITMQ_RETRY_DELAY": "30s", "RABBITMQ_MAX_RETRIES": "5"},
+			want: Config{Queue: Queue, MessageTTL: 24 * time.Hour, RetryDelay: 30 * time.Second, MaxRetries: 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"RABBITMQ_MESSAGE_TTL", "RABBITMQ_RETRY_DELAY", "RABBITMQ_MAX_RETRIES"} {
+				t.Setenv(key, tt.env[key])
+			}
+			if got := ConfigFromEnv(); got != tt.want {
+				t.Errorf("ConfigFromEnv() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}